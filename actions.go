@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// stackController changes the state of a stack.
+type stackController interface {
+	CancelUpdate(ctx context.Context) error
+	ContinueRollback(ctx context.Context, skip []string) error
+}
+
+// cancelUpdate asks for confirmation before cancelling the update in
+// progress. It returns an error if the update cannot be cancelled.
+func (a *app) cancelUpdate(ctx context.Context, snap *fetcher.Snapshot, results chan<- string) (*prompt, error) {
+	if a.controller == nil {
+		return nil, fmt.Errorf("read only: cancelling updates is disabled")
+	}
+	if snap == nil {
+		return nil, fmt.Errorf("the stack does not exist yet")
+	}
+	if snap.Stack.Status != types.StackStatusUpdateInProgress {
+		return nil, fmt.Errorf("only an update in progress can be cancelled, stack is %s", snap.Stack.Status)
+	}
+
+	question := fmt.Sprintf("Cancel the update of %s? The stack will roll back.", a.stackName)
+	return confirm(question, func() *prompt {
+		a.runAction(ctx, "cancel update", results, a.controller.CancelUpdate)
+		return nil
+	}), nil
+}
+
+// continueRollback lets the user choose failed resources to skip, then asks
+// for confirmation before continuing a failed rollback. It returns an error
+// if the rollback cannot be continued.
+func (a *app) continueRollback(ctx context.Context, snap *fetcher.Snapshot, results chan<- string) (*prompt, error) {
+	if a.controller == nil {
+		return nil, fmt.Errorf("read only: continuing rollbacks is disabled")
+	}
+	if snap == nil {
+		return nil, fmt.Errorf("the stack does not exist yet")
+	}
+	if snap.Stack.Status != types.StackStatusUpdateRollbackFailed {
+		return nil, fmt.Errorf("only a failed rollback can be continued, stack is %s", snap.Stack.Status)
+	}
+
+	confirmSkip := func(skip []string) *prompt {
+		question := fmt.Sprintf("Continue rolling back %s?", a.stackName)
+		if len(skip) > 0 {
+			question = fmt.Sprintf("Continue rolling back %s, skipping %s?", a.stackName, strings.Join(skip, ", "))
+		}
+		return confirm(question, func() *prompt {
+			a.runAction(ctx, "continue rollback", results, func(ctx context.Context) error {
+				return a.controller.ContinueRollback(ctx, skip)
+			})
+			return nil
+		})
+	}
+
+	var failed []string
+	for _, r := range snap.Resources {
+		if status.Resource(r.Status) == status.Failed {
+			failed = append(failed, r.Resource)
+		}
+	}
+	if len(failed) == 0 {
+		return confirmSkip(nil), nil
+	}
+	return choose("Resources to skip, which are left in their current state:", failed, confirmSkip), nil
+}
+
+// runAction calls fn in the background, reporting the outcome on results.
+func (a *app) runAction(ctx context.Context, name string, results chan<- string, fn func(context.Context) error) {
+	go func() {
+		if err := fn(ctx); err != nil {
+			results <- fmt.Sprintf("%s failed: %v", name, err)
+			return
+		}
+		results <- name + " requested"
+	}()
+}
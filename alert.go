@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/simonrw/cflivestatus/notify"
+)
+
+// flashDuration is how long the header is highlighted after an alert.
+const flashDuration = 3 * time.Second
+
+// alertConfig selects which terminal alerts are raised for each trigger.
+type alertConfig struct {
+	bell    map[notify.Trigger]bool
+	desktop map[notify.Trigger]bool
+	flash   map[notify.Trigger]bool
+
+	// osc is the desktop notification escape sequence to emit: "9", "777"
+	// or "both".
+	osc string
+}
+
+// alertNotifier forwards events to the render loop, which owns the screen.
+type alertNotifier chan notify.Event
+
+func (a alertNotifier) Notify(ctx context.Context, e notify.Event) error {
+	select {
+	case a <- e:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Alert raises the configured terminal alerts for the event. It returns
+// whether the header has started flashing, in which case the caller should
+// redraw the screen once flashDuration has passed.
+func (s *Screen) Alert(e notify.Event, cfg alertConfig) bool {
+	if cfg.bell[e.Trigger] {
+		_ = (*s.s).Beep()
+	}
+	if cfg.desktop[e.Trigger] {
+		s.desktopNotify("cflivestatus", e.Summary(), cfg.osc)
+	}
+	if cfg.flash[e.Trigger] {
+		s.Flash(e.Summary())
+		return true
+	}
+	return false
+}
+
+// desktopNotify writes the notification escape sequences straight to the
+// terminal, as tcell has no API for them. Terminals which do not understand
+// the sequences ignore them.
+func (s *Screen) desktopNotify(title, body, osc string) {
+	tty, ok := (*s.s).Tty()
+	if !ok {
+		return
+	}
+	for _, seq := range oscSequences(title, body, osc) {
+		_, _ = tty.Write([]byte(seq))
+	}
+}
+
+func oscSequences(title, body, osc string) []string {
+	title = sanitiseOSC(title)
+	body = sanitiseOSC(body)
+
+	var out []string
+	if osc == "9" || osc == "both" {
+		// iTerm2, WezTerm, kitty
+		out = append(out, fmt.Sprintf("\x1b]9;%s: %s\x07", title, body))
+	}
+	if osc == "777" || osc == "both" {
+		// foot, WezTerm, rxvt derivatives; fields are separated by ';'
+		out = append(out, fmt.Sprintf("\x1b]777;notify;%s;%s\x07", strings.ReplaceAll(title, ";", ","), body))
+	}
+	return out
+}
+
+// sanitiseOSC removes characters which would terminate the escape sequence
+// early.
+func sanitiseOSC(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+func newAlertConfig(bellOn, desktopOn, flashOn []string, osc string) (alertConfig, error) {
+	cfg := alertConfig{osc: osc}
+	var err error
+	if cfg.bell, err = triggerSet(bellOn); err != nil {
+		return cfg, err
+	}
+	if cfg.desktop, err = triggerSet(desktopOn); err != nil {
+		return cfg, err
+	}
+	if cfg.flash, err = triggerSet(flashOn); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func triggerSet(names []string) (map[notify.Trigger]bool, error) {
+	triggers, err := parseTriggers(names)
+	if err != nil {
+		return nil, err
+	}
+	out := map[notify.Trigger]bool{}
+	for _, t := range triggers {
+		out[t] = true
+	}
+	return out, nil
+}
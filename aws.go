@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// awsOptions select the account, region and endpoint to talk to.
+type awsOptions struct {
+	Profile         string `long:"profile" description:"Shared config profile to use"`
+	Region          string `long:"region" description:"AWS region of the stack"`
+	RoleARN         string `long:"role-arn" description:"Assume this IAM role before accessing CloudFormation"`
+	RoleSessionName string `long:"role-session-name" description:"Session name used when assuming --role-arn" default:"cflivestatus"`
+	EndpointURL     string `long:"endpoint-url" description:"Override the AWS endpoint, e.g. for LocalStack"`
+}
+
+// identity is the account and region being watched, shown so that nobody
+// watches the wrong account by mistake.
+type identity struct {
+	Account string
+	Region  string
+}
+
+func (id identity) String() string {
+	account := id.Account
+	if account == "" {
+		account = "unknown"
+	}
+	return fmt.Sprintf("account %s (%s)", account, id.Region)
+}
+
+func loadAWSConfig(ctx context.Context, opts awsOptions) (aws.Config, error) {
+	loadOpts := []func(*config.LoadOptions) error{}
+	if opts.Profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(opts.Profile))
+	}
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(opts.Region))
+	}
+	if opts.EndpointURL != "" {
+		loadOpts = append(loadOpts, config.WithBaseEndpoint(opts.EndpointURL))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return cfg, fmt.Errorf("loading AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		return cfg, fmt.Errorf("no AWS region configured, pass --region or set AWS_REGION")
+	}
+
+	if opts.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), opts.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = opts.RoleSessionName
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return cfg, nil
+}
+
+func callerIdentity(ctx context.Context, cfg aws.Config) (identity, error) {
+	id := identity{Region: cfg.Region}
+	res, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return id, fmt.Errorf("getting caller identity: %w", err)
+	}
+	if res.Account != nil {
+		id.Account = *res.Account
+	}
+	return id, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// isolateAWS keeps the environment and shared config of whoever runs the
+// tests out of the way, leaving a profile called test in eu-west-2 and
+// credentials for it.
+func isolateAWS(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config":      "[profile test]\nregion = eu-west-2\n",
+		"credentials": "[test]\naws_access_key_id = AKIDPROFILE\naws_secret_access_key = secret\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	for _, name := range []string{"AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_STS", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDBASE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+// fakeSTS answers AssumeRole and GetCallerIdentity, recording the form of
+// each request.
+func fakeSTS(t *testing.T) (*httptest.Server, *[]map[string]string) {
+	var calls []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		call := map[string]string{}
+		for k := range r.PostForm {
+			call[k] = r.PostForm.Get(k)
+		}
+		calls = append(calls, call)
+
+		w.Header().Set("Content-Type", "text/xml")
+		switch call["Action"] {
+		case "AssumeRole":
+			fmt.Fprint(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>
+<AccessKeyId>AKIDROLE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken>
+<Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+		case "GetCallerIdentity":
+			fmt.Fprint(w, `<GetCallerIdentityResponse><GetCallerIdentityResult>
+<Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`)
+		default:
+			t.Errorf("unexpected STS action %q", call["Action"])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestLoadAWSConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		opts       awsOptions
+		wantRegion string
+		wantKey    string
+	}{
+		{"profile", awsOptions{Profile: "test"}, "eu-west-2", "AKIDPROFILE"},
+		{"region", awsOptions{Region: "us-east-1"}, "us-east-1", "AKIDBASE"},
+		{"region overrides profile", awsOptions{Profile: "test", Region: "us-east-1"}, "us-east-1", "AKIDPROFILE"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			isolateAWS(t)
+
+			cfg, err := loadAWSConfig(context.Background(), tc.opts)
+			is.NoErr(err)
+			is.Equal(cfg.Region, tc.wantRegion)
+			is.Equal(cfg.BaseEndpoint, nil)
+
+			creds, err := cfg.Credentials.Retrieve(context.Background())
+			is.NoErr(err)
+			is.Equal(creds.AccessKeyID, tc.wantKey)
+		})
+	}
+}
+
+func TestLoadAWSConfigErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts awsOptions
+	}{
+		{"no region", awsOptions{}},
+		{"unknown profile", awsOptions{Profile: "missing", Region: "us-east-1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			isolateAWS(t)
+
+			_, err := loadAWSConfig(context.Background(), tc.opts)
+			is.True(err != nil)
+		})
+	}
+}
+
+func TestLoadAWSConfigAssumesRole(t *testing.T) {
+	is := is.New(t)
+	isolateAWS(t)
+	srv, calls := fakeSTS(t)
+
+	cfg, err := loadAWSConfig(context.Background(), awsOptions{
+		Region:          "us-east-1",
+		EndpointURL:     srv.URL,
+		RoleARN:         "arn:aws:iam::123456789012:role/deploy",
+		RoleSessionName: "ci",
+	})
+	is.NoErr(err)
+	is.Equal(*cfg.BaseEndpoint, srv.URL)
+
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	is.NoErr(err)
+	is.Equal(creds.AccessKeyID, "AKIDROLE")
+	is.Equal(len(*calls), 1)
+	is.Equal((*calls)[0]["Action"], "AssumeRole")
+	is.Equal((*calls)[0]["RoleArn"], "arn:aws:iam::123456789012:role/deploy")
+	is.Equal((*calls)[0]["RoleSessionName"], "ci")
+}
+
+func TestCallerIdentity(t *testing.T) {
+	is := is.New(t)
+	isolateAWS(t)
+	srv, _ := fakeSTS(t)
+
+	cfg, err := loadAWSConfig(context.Background(), awsOptions{Region: "us-east-1", EndpointURL: srv.URL})
+	is.NoErr(err)
+	id, err := callerIdentity(context.Background(), cfg)
+	is.NoErr(err)
+	is.Equal(id, identity{Account: "123456789012", Region: "us-east-1"})
+	is.Equal(id.String(), "account 123456789012 (us-east-1)")
+}
+
+func TestCallerIdentityError(t *testing.T) {
+	is := is.New(t)
+	isolateAWS(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>denied</Message></Error></ErrorResponse>`)
+	}))
+	defer srv.Close()
+
+	cfg, err := loadAWSConfig(context.Background(), awsOptions{Region: "us-east-1", EndpointURL: srv.URL})
+	is.NoErr(err)
+	id, err := callerIdentity(context.Background(), cfg)
+	is.True(err != nil)
+	is.Equal(id, identity{Region: "us-east-1"})
+	is.Equal(id.String(), "account unknown (us-east-1)")
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/simonrw/cflivestatus/cdk"
+)
+
+// loadCDKStack reads the CDK cloud assembly in dir and finds the stack to
+// watch, which may be given by its artifact ID or, if the app has only one
+// stack, not at all. It returns the stack's name and the construct paths of
+// its resources.
+func loadCDKStack(dir, name string) (string, map[string]string, error) {
+	stacks, err := cdk.Load(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	s, err := cdk.Find(stacks, name)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.Name, s.Paths, nil
+}
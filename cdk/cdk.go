@@ -0,0 +1,171 @@
+// Package cdk reads the cloud assembly which the CDK synthesises, usually
+// into cdk.out, to find the stacks in an app and the construct each
+// resource belongs to.
+package cdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	stackArtifact    = "aws:cloudformation:stack"
+	assemblyArtifact = "cdk:cloud-assembly"
+	logicalIDEntry   = "aws:cdk:logicalId"
+	pathMetadata     = "aws:cdk:path"
+)
+
+// Stack is a stack synthesised by the app.
+type Stack struct {
+	// ID is the artifact's ID, which the CDK CLI uses to select stacks.
+	ID string
+	// Name is the name of the CloudFormation stack.
+	Name string
+	// Paths maps logical IDs to construct paths, relative to the stack,
+	// e.g. Api/Handler/ServiceRole.
+	Paths map[string]string
+}
+
+type manifest struct {
+	Artifacts map[string]artifact `json:"artifacts"`
+}
+
+type artifact struct {
+	Type        string `json:"type"`
+	DisplayName string `json:"displayName"`
+	Properties  struct {
+		TemplateFile  string `json:"templateFile"`
+		StackName     string `json:"stackName"`
+		DirectoryName string `json:"directoryName"`
+	} `json:"properties"`
+	Metadata map[string][]struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	} `json:"metadata"`
+}
+
+type cdkTemplate struct {
+	Resources map[string]struct {
+		Metadata map[string]interface{} `json:"Metadata"`
+	} `json:"Resources"`
+}
+
+// Load reads the stacks from the cloud assembly in dir, including those in
+// nested assemblies such as stages, sorted by name.
+func Load(dir string) ([]Stack, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading cloud assembly manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parsing cloud assembly manifest: %w", err)
+	}
+
+	var stacks []Stack
+	for id, a := range m.Artifacts {
+		switch a.Type {
+		case stackArtifact:
+			s, err := loadStack(dir, id, a)
+			if err != nil {
+				return nil, err
+			}
+			stacks = append(stacks, s)
+		case assemblyArtifact:
+			nested, err := Load(filepath.Join(dir, a.Properties.DirectoryName))
+			if err != nil {
+				return nil, err
+			}
+			stacks = append(stacks, nested...)
+		}
+	}
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Name < stacks[j].Name })
+	return stacks, nil
+}
+
+func loadStack(dir, id string, a artifact) (Stack, error) {
+	s := Stack{ID: id, Name: a.Properties.StackName, Paths: map[string]string{}}
+	if s.Name == "" {
+		s.Name = id
+	}
+	// construct paths begin with the path of the stack itself
+	prefix := a.DisplayName
+	if prefix == "" {
+		prefix = id
+	}
+
+	// the manifest only records logical IDs when they have been overridden
+	// or the template is too large, so the template is the main source
+	for path, entries := range a.Metadata {
+		for _, e := range entries {
+			var logicalID string
+			if e.Type != logicalIDEntry || json.Unmarshal(e.Data, &logicalID) != nil {
+				continue
+			}
+			s.Paths[logicalID] = relativePath(path, prefix)
+		}
+	}
+
+	if a.Properties.TemplateFile == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(filepath.Join(dir, a.Properties.TemplateFile))
+	if err != nil {
+		return Stack{}, fmt.Errorf("reading template for %s: %w", id, err)
+	}
+	var t cdkTemplate
+	if err := json.Unmarshal(b, &t); err != nil {
+		return Stack{}, fmt.Errorf("parsing template for %s: %w", id, err)
+	}
+	for logicalID, r := range t.Resources {
+		if path, ok := r.Metadata[pathMetadata].(string); ok {
+			s.Paths[logicalID] = relativePath(path, prefix)
+		}
+	}
+	return s, nil
+}
+
+// relativePath removes the stack's own path from a construct path, along
+// with the name the CDK gives the CloudFormation resource wrapped by a
+// higher level construct.
+func relativePath(path, prefix string) string {
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimPrefix(path, prefix+"/")
+	for _, child := range []string{"/Resource", "/Default"} {
+		path = strings.TrimSuffix(path, child)
+	}
+	return path
+}
+
+// Find returns the stack with the given stack name or artifact ID. If name
+// is empty and the app has a single stack, that stack is returned.
+func Find(stacks []Stack, name string) (Stack, error) {
+	if len(stacks) == 0 {
+		return Stack{}, fmt.Errorf("the app has no stacks")
+	}
+	if name == "" {
+		if len(stacks) == 1 {
+			return stacks[0], nil
+		}
+		return Stack{}, fmt.Errorf("the app has %d stacks, choose one of: %s", len(stacks), strings.Join(Names(stacks), ", "))
+	}
+	for _, s := range stacks {
+		if s.Name == name || s.ID == name {
+			return s, nil
+		}
+	}
+	return Stack{}, fmt.Errorf("the app has no stack %q, choose one of: %s", name, strings.Join(Names(stacks), ", "))
+}
+
+// Names returns the names of the stacks.
+func Names(stacks []Stack) []string {
+	names := make([]string, 0, len(stacks))
+	for _, s := range stacks {
+		names = append(names, s.Name)
+	}
+	return names
+}
@@ -0,0 +1,109 @@
+package cdk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	is := is.New(t)
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "manifest.json"), `{
+  "version": "36.0.0",
+  "artifacts": {
+    "ApiStack.assets": {"type": "cdk:asset-manifest", "properties": {"file": "ApiStack.assets.json"}},
+    "ApiStack": {
+      "type": "aws:cloudformation:stack",
+      "displayName": "ApiStack",
+      "properties": {"templateFile": "ApiStack.template.json", "stackName": "my-api"},
+      "metadata": {
+        "/ApiStack/Legacy/Resource": [{"type": "aws:cdk:logicalId", "data": "LegacyOverridden"}],
+        "/ApiStack/Api/Handler/Resource": [{"type": "aws:cdk:warning", "data": "ignored"}]
+      }
+    },
+    "Prod": {"type": "cdk:cloud-assembly", "properties": {"directoryName": "assembly-Prod", "displayName": "Prod"}}
+  }
+}`)
+	writeFile(t, filepath.Join(dir, "ApiStack.template.json"), `{
+  "Resources": {
+    "ApiHandlerServiceRole12345678": {"Type": "AWS::IAM::Role", "Metadata": {"aws:cdk:path": "ApiStack/Api/Handler/ServiceRole/Resource"}},
+    "ApiHandler87654321": {"Type": "AWS::Lambda::Function", "Metadata": {"aws:cdk:path": "ApiStack/Api/Handler/Resource"}},
+    "CDKMetadata": {"Type": "AWS::CDK::Metadata", "Metadata": {"aws:cdk:path": "ApiStack/CDKMetadata/Default"}},
+    "Plain": {"Type": "AWS::SNS::Topic"}
+  }
+}`)
+	writeFile(t, filepath.Join(dir, "assembly-Prod", "manifest.json"), `{
+  "artifacts": {
+    "ProdDataStackA1B2C3D4": {
+      "type": "aws:cloudformation:stack",
+      "displayName": "Prod/DataStack",
+      "properties": {"templateFile": "ProdDataStackA1B2C3D4.template.json", "stackName": "Prod-DataStack"}
+    }
+  }
+}`)
+	writeFile(t, filepath.Join(dir, "assembly-Prod", "ProdDataStackA1B2C3D4.template.json"), `{
+  "Resources": {
+    "TableCD117FA1": {"Type": "AWS::DynamoDB::Table", "Metadata": {"aws:cdk:path": "Prod/DataStack/Table/Resource"}}
+  }
+}`)
+
+	stacks, err := Load(dir)
+	is.NoErr(err)
+	is.Equal(Names(stacks), []string{"Prod-DataStack", "my-api"})
+
+	api, err := Find(stacks, "ApiStack")
+	is.NoErr(err)
+	is.Equal(api.Name, "my-api")
+	is.Equal(api.Paths, map[string]string{
+		"ApiHandlerServiceRole12345678": "Api/Handler/ServiceRole",
+		"ApiHandler87654321":            "Api/Handler",
+		"CDKMetadata":                   "CDKMetadata",
+		"LegacyOverridden":              "Legacy",
+	})
+
+	data, err := Find(stacks, "Prod-DataStack")
+	is.NoErr(err)
+	is.Equal(data.Paths["TableCD117FA1"], "Table")
+}
+
+func TestLoadMissing(t *testing.T) {
+	is := is.New(t)
+
+	_, err := Load(t.TempDir())
+	is.True(err != nil)
+}
+
+func TestFind(t *testing.T) {
+	is := is.New(t)
+
+	one := []Stack{{ID: "App", Name: "app"}}
+	s, err := Find(one, "")
+	is.NoErr(err)
+	is.Equal(s.Name, "app")
+
+	two := append(one, Stack{ID: "Other", Name: "other"})
+	_, err = Find(two, "")
+	is.True(err != nil)
+	_, err = Find(two, "missing")
+	is.True(err != nil)
+	s, err = Find(two, "Other")
+	is.NoErr(err)
+	is.Equal(s.Name, "other")
+
+	_, err = Find(nil, "")
+	is.True(err != nil)
+}
@@ -0,0 +1,136 @@
+// Package changes tracks how the resources of a stack change between
+// consecutive snapshots.
+package changes
+
+import (
+	"sort"
+	"time"
+
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+// Kind describes how a resource differs from the previous snapshot.
+type Kind int
+
+const (
+	// Unchanged resources have the same status as before.
+	Unchanged Kind = iota
+	// Added resources were not in the previous snapshot.
+	Added
+	// Changed resources have a different status.
+	Changed
+	// Removed resources are no longer part of the stack.
+	Removed
+)
+
+// Change is what is known about the most recent change to a resource.
+type Change struct {
+	Kind Kind
+	// At is when the change was observed, or the resource's own timestamp
+	// for resources already present in the first snapshot.
+	At time.Time
+}
+
+// Tracker compares consecutive snapshots. Changes are highlighted for
+// Highlight after they are seen, and removed resources are remembered for
+// Retain.
+type Tracker struct {
+	Highlight time.Duration
+	Retain    time.Duration
+
+	prev    map[string]fetcher.StackResource
+	changes map[string]Change
+	removed map[string]fetcher.StackResource
+}
+
+// NewTracker creates a tracker with the default highlight and retention
+// durations.
+func NewTracker() *Tracker {
+	return &Tracker{
+		Highlight: 4 * time.Second,
+		Retain:    30 * time.Second,
+		changes:   map[string]Change{},
+		removed:   map[string]fetcher.StackResource{},
+	}
+}
+
+// Observe records a new snapshot. The first snapshot only establishes the
+// baseline, so nothing in it is highlighted.
+func (t *Tracker) Observe(snap *fetcher.Snapshot) {
+	current := make(map[string]fetcher.StackResource, len(snap.Resources))
+	for _, r := range snap.Resources {
+		current[r.Resource] = r
+	}
+
+	for name, r := range current {
+		prev, seen := t.prev[name]
+		switch {
+		case t.prev == nil:
+			t.changes[name] = Change{Kind: Unchanged, At: r.Timestamp}
+		case !seen:
+			t.changes[name] = Change{Kind: Added, At: snap.Time}
+			delete(t.removed, name)
+		case prev.Status != r.Status:
+			t.changes[name] = Change{Kind: Changed, At: snap.Time}
+		}
+	}
+
+	for name, r := range t.prev {
+		if _, ok := current[name]; !ok {
+			t.changes[name] = Change{Kind: Removed, At: snap.Time}
+			t.removed[name] = r
+		}
+	}
+
+	for name := range t.removed {
+		if snap.Time.Sub(t.changes[name].At) >= t.Retain {
+			delete(t.removed, name)
+			delete(t.changes, name)
+		}
+	}
+
+	t.prev = current
+}
+
+// Change returns the most recent change to the named resource.
+func (t *Tracker) Change(name string) (Change, bool) {
+	c, ok := t.changes[name]
+	return c, ok
+}
+
+// Removed returns the resources that recently disappeared from the stack,
+// ordered by name.
+func (t *Tracker) Removed() []fetcher.StackResource {
+	out := make([]fetcher.StackResource, 0, len(t.removed))
+	for _, r := range t.removed {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Resource < out[j].Resource })
+	return out
+}
+
+// Fade returns how strongly a change should be highlighted at now, from 1
+// when it has just been observed down to 0 once Highlight has passed.
+func (t *Tracker) Fade(c Change, now time.Time) float64 {
+	if c.Kind == Unchanged || t.Highlight <= 0 {
+		return 0
+	}
+	age := now.Sub(c.At)
+	if age < 0 {
+		return 1
+	}
+	if age >= t.Highlight {
+		return 0
+	}
+	return 1 - float64(age)/float64(t.Highlight)
+}
+
+// Highlighting returns whether any change is still fading at now.
+func (t *Tracker) Highlighting(now time.Time) bool {
+	for _, c := range t.changes {
+		if t.Fade(c, now) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package changes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func snapshot(after time.Duration, resources ...fetcher.StackResource) *fetcher.Snapshot {
+	return &fetcher.Snapshot{Time: start.Add(after), Resources: resources}
+}
+
+func resource(name string, status types.ResourceStatus) fetcher.StackResource {
+	return fetcher.StackResource{Resource: name, Status: status, Timestamp: start.Add(-time.Minute)}
+}
+
+func TestBaselineIsNotHighlighted(t *testing.T) {
+	is := is.New(t)
+
+	tr := NewTracker()
+	tr.Observe(snapshot(0, resource("Bucket", types.ResourceStatusCreateComplete)))
+
+	c, ok := tr.Change("Bucket")
+	is.True(ok)
+	is.Equal(c.Kind, Unchanged)
+	is.Equal(c.At, start.Add(-time.Minute)) // the resource's own timestamp
+	is.True(!tr.Highlighting(start))
+}
+
+func TestStatusChange(t *testing.T) {
+	is := is.New(t)
+
+	tr := NewTracker()
+	tr.Observe(snapshot(0, resource("Bucket", types.ResourceStatusUpdateInProgress)))
+	tr.Observe(snapshot(5*time.Second, resource("Bucket", types.ResourceStatusUpdateInProgress)))
+
+	c, _ := tr.Change("Bucket")
+	is.Equal(c.Kind, Unchanged)
+
+	tr.Observe(snapshot(10*time.Second, resource("Bucket", types.ResourceStatusUpdateComplete)))
+	c, _ = tr.Change("Bucket")
+	is.Equal(c.Kind, Changed)
+	is.Equal(c.At, start.Add(10*time.Second))
+
+	is.Equal(tr.Fade(c, start.Add(10*time.Second)), 1.0)
+	is.Equal(tr.Fade(c, start.Add(12*time.Second)), 0.5)
+	is.Equal(tr.Fade(c, start.Add(14*time.Second)), 0.0)
+	is.True(tr.Highlighting(start.Add(11 * time.Second)))
+	is.True(!tr.Highlighting(start.Add(15 * time.Second)))
+
+	// the change is remembered while the status stays the same
+	tr.Observe(snapshot(15*time.Second, resource("Bucket", types.ResourceStatusUpdateComplete)))
+	c, _ = tr.Change("Bucket")
+	is.Equal(c.Kind, Changed)
+	is.Equal(c.At, start.Add(10*time.Second))
+}
+
+func TestAddedAndRemoved(t *testing.T) {
+	is := is.New(t)
+
+	tr := NewTracker()
+	tr.Observe(snapshot(0, resource("Old", types.ResourceStatusCreateComplete)))
+	tr.Observe(snapshot(5*time.Second, resource("New", types.ResourceStatusCreateInProgress)))
+
+	c, _ := tr.Change("New")
+	is.Equal(c.Kind, Added)
+	c, _ = tr.Change("Old")
+	is.Equal(c.Kind, Removed)
+
+	removed := tr.Removed()
+	is.Equal(len(removed), 1)
+	is.Equal(removed[0].Resource, "Old")
+	is.Equal(removed[0].Status, types.ResourceStatusCreateComplete)
+
+	// removed resources are forgotten after the retention period
+	tr.Observe(snapshot(40*time.Second, resource("New", types.ResourceStatusCreateComplete)))
+	is.Equal(len(tr.Removed()), 0)
+	_, ok := tr.Change("Old")
+	is.True(!ok)
+}
+
+func TestReappearingResourceIsNotRemoved(t *testing.T) {
+	is := is.New(t)
+
+	tr := NewTracker()
+	tr.Observe(snapshot(0, resource("Queue", types.ResourceStatusCreateComplete)))
+	tr.Observe(snapshot(5 * time.Second))
+	is.Equal(len(tr.Removed()), 1)
+
+	tr.Observe(snapshot(10*time.Second, resource("Queue", types.ResourceStatusCreateInProgress)))
+	is.Equal(len(tr.Removed()), 0)
+	c, _ := tr.Change("Queue")
+	is.Equal(c.Kind, Added)
+}
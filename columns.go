@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// column is a field of the resource table.
+type column string
+
+const (
+	columnResource   column = "resource"
+	columnType       column = "type"
+	columnStatus     column = "status"
+	columnPhysicalID column = "physical-id"
+	columnUpdated    column = "updated"
+	columnElapsed    column = "elapsed"
+	columnReason     column = "reason"
+)
+
+var allColumns = []column{columnResource, columnType, columnStatus, columnPhysicalID, columnUpdated, columnElapsed, columnReason}
+
+// displayOptions control the layout of the resource table.
+type displayOptions struct {
+	columns []column
+	sort    string
+	// stuckAfter is how long a resource may be in progress before it is
+	// highlighted as possibly stuck, or zero to never highlight it.
+	stuckAfter time.Duration
+}
+
+func parseColumns(s string) ([]column, error) {
+	out := []column{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, c := range allColumns {
+			if string(c) == name {
+				out = append(out, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+	return out, nil
+}
+
+func columnValue(r fetcher.StackResource, c column, now time.Time) string {
+	switch c {
+	case columnResource:
+		return r.Resource
+	case columnType:
+		return r.Type
+	case columnStatus:
+		return string(r.Status)
+	case columnPhysicalID:
+		return r.PhysicalID
+	case columnUpdated:
+		if r.Timestamp.IsZero() {
+			return ""
+		}
+		return r.Timestamp.Local().Format("15:04:05")
+	case columnElapsed:
+		// only resources still changing are timed, as how long ago the
+		// others settled is shown at the end of the row
+		if r.Timestamp.IsZero() || !status.Resource(r.Status).Active() {
+			return ""
+		}
+		return "for " + formatAge(now.Sub(r.Timestamp))
+	case columnReason:
+		return r.Reason
+	default:
+		return ""
+	}
+}
+
+func columnWidths(res []fetcher.StackResource, cols []column, now time.Time) map[column]int {
+	widths := map[column]int{}
+	for _, r := range res {
+		for _, c := range cols {
+			if l := len(columnValue(r, c, now)); l > widths[c] {
+				widths[c] = l
+			}
+		}
+	}
+	return widths
+}
+
+// formatRow lays out a resource as "Resource: STATUS (reason)", with any
+// other columns aligned between the resource name and the reason.
+func formatRow(r fetcher.StackResource, cols []column, widths map[column]int, now time.Time) string {
+	var b strings.Builder
+	for i, c := range cols {
+		v := columnValue(r, c, now)
+		if i > 0 && (c != columnReason || v != "") {
+			b.WriteByte(' ')
+		}
+		switch c {
+		case columnResource:
+			fmt.Fprintf(&b, "%*s:", widths[c], v)
+		case columnReason:
+			if v != "" {
+				fmt.Fprintf(&b, "(%s)", v)
+			}
+		default:
+			if lastPadded(cols, i) {
+				b.WriteString(v)
+			} else {
+				fmt.Fprintf(&b, "%-*s", widths[c], v)
+			}
+		}
+	}
+	return b.String()
+}
+
+// lastPadded returns whether no aligned columns follow column i, in which
+// case it need not be padded.
+func lastPadded(cols []column, i int) bool {
+	for _, c := range cols[i+1:] {
+		if c != columnReason && c != columnResource {
+			return false
+		}
+	}
+	return true
+}
+
+func sortResources(res []fetcher.StackResource, order string) {
+	sort.Sort(byName(res))
+	switch order {
+	case "status":
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Status < res[j].Status })
+	case "type":
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Type < res[j].Type })
+	case "updated":
+		sort.SliceStable(res, func(i, j int) bool { return res[i].Timestamp.After(res[j].Timestamp) })
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+var now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestElapsedColumn(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		r    fetcher.StackResource
+		want string
+	}{
+		{"in progress", fetcher.StackResource{Status: types.ResourceStatusCreateInProgress, Timestamp: now.Add(-252 * time.Second)}, "for 4m12s"},
+		{"rolling back", fetcher.StackResource{Status: types.ResourceStatusDeleteInProgress, Timestamp: now.Add(-5 * time.Second)}, "for 5s"},
+		{"settled", fetcher.StackResource{Status: types.ResourceStatusCreateComplete, Timestamp: now.Add(-time.Hour)}, ""},
+		{"failed", fetcher.StackResource{Status: types.ResourceStatusCreateFailed, Timestamp: now.Add(-time.Hour)}, ""},
+		{"pending", fetcher.StackResource{Status: status.ResourcePending}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(columnValue(tc.r, columnElapsed, now), tc.want)
+		})
+	}
+}
+
+func TestFormatRowElapsed(t *testing.T) {
+	is := is.New(t)
+
+	cols := []column{columnResource, columnStatus, columnElapsed, columnReason}
+	rows := []fetcher.StackResource{
+		{Resource: "Db", Status: types.ResourceStatusCreateInProgress, Timestamp: now.Add(-252 * time.Second), Reason: "Resource creation Initiated"},
+		{Resource: "Bucket", Status: types.ResourceStatusCreateComplete, Timestamp: now.Add(-time.Hour)},
+	}
+	widths := columnWidths(rows, cols, now)
+	is.Equal(formatRow(rows[0], cols, widths, now), "    Db: CREATE_IN_PROGRESS for 4m12s (Resource creation Initiated)")
+	is.Equal(formatRow(rows[1], cols, widths, now), "Bucket: CREATE_COMPLETE    ")
+}
+
+func TestStuck(t *testing.T) {
+	inProgress := func(d time.Duration) fetcher.StackResource {
+		return fetcher.StackResource{Status: types.ResourceStatusUpdateInProgress, Timestamp: now.Add(-d)}
+	}
+	for _, tc := range []struct {
+		name       string
+		stuckAfter time.Duration
+		r          fetcher.StackResource
+		want       bool
+	}{
+		{"under the threshold", 15 * time.Minute, inProgress(14 * time.Minute), false},
+		{"at the threshold", 15 * time.Minute, inProgress(15 * time.Minute), true},
+		{"over the threshold", 15 * time.Minute, inProgress(time.Hour), true},
+		{"disabled", 0, inProgress(time.Hour), false},
+		{"settled", 15 * time.Minute, fetcher.StackResource{Status: types.ResourceStatusUpdateComplete, Timestamp: now.Add(-time.Hour)}, false},
+		{"no timestamp", 15 * time.Minute, fetcher.StackResource{Status: types.ResourceStatusUpdateInProgress}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			s := &Screen{display: displayOptions{stuckAfter: tc.stuckAfter}}
+			is.Equal(s.stuck(tc.r, now), tc.want)
+		})
+	}
+}
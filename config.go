@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/simonrw/cflivestatus/settings"
+	"github.com/simonrw/cflivestatus/theme"
+)
+
+type configCommand struct {
+	Show struct{} `command:"show" description:"Print the effective configuration, merging the config file and command line"`
+}
+
+// loadSettings applies the configuration file to the parser's options. It
+// must be called before the command line is parsed, so that flags take
+// precedence over the file. The settings are returned so that map options
+// can be merged with the command line afterwards by mergeMapSettings.
+func loadSettings(parser *flags.Parser, args []string) (*settings.File, []settings.Setting, error) {
+	// the config file location is itself a flag, so find it first
+	var pre struct {
+		ConfigFile    string `long:"config"`
+		ConfigProfile string `long:"config-profile"`
+	}
+	if _, err := flags.NewParser(&pre, flags.IgnoreUnknown).ParseArgs(args); err != nil {
+		return nil, nil, err
+	}
+
+	path := pre.ConfigFile
+	required := path != ""
+	if path == "" {
+		path = settings.DefaultPath()
+	}
+	file, err := settings.Load(path, required)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err := file.Profile(pre.ConfigProfile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range values {
+		opt, value := settingOption(parser, s)
+		if opt == nil {
+			return nil, nil, fmt.Errorf("%s:%d: unknown setting %q", file.Path, s.Line, s.Key)
+		}
+		if err := opt.Set(&value); err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %s: %w", file.Path, s.Line, s.Key, err)
+		}
+	}
+	return file, values, nil
+}
+
+// settingOption finds the option a setting applies to, and the value to set
+// it to. Map options such as colour.failed are written name.key = value.
+func settingOption(parser *flags.Parser, s settings.Setting) (*flags.Option, string) {
+	name, value := s.Key, s.Value
+	if n, key, ok := strings.Cut(s.Key, "."); ok {
+		name, value = n, key+":"+s.Value
+	}
+	if name == "config" || name == "config-profile" {
+		return nil, ""
+	}
+	return parser.FindOptionByLongName(name), value
+}
+
+// mergeMapSettings restores map entries from the configuration file which
+// were dropped when the same option was given on the command line, so that
+// e.g. --colour ok:lime only replaces the ok colour.
+func mergeMapSettings(parser *flags.Parser, values []settings.Setting) {
+	for _, s := range values {
+		opt, _ := settingOption(parser, s)
+		_, key, ok := strings.Cut(s.Key, ".")
+		if opt == nil || !ok {
+			continue
+		}
+		m := reflect.ValueOf(opt.Value())
+		if m.Kind() != reflect.Map || m.IsNil() || m.MapIndex(reflect.ValueOf(key)).IsValid() {
+			continue
+		}
+		m.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(s.Value))
+	}
+}
+
+// showConfig writes the value of every option in the configuration file
+// format.
+func showConfig(w io.Writer, parser *flags.Parser, file *settings.File, profile string) {
+	fmt.Fprintf(w, "# config file: %s\n", file.Path)
+	if profile != "" {
+		fmt.Fprintf(w, "# profile: %s\n", profile)
+	}
+	fmt.Fprintln(w, "[default]")
+
+	var walk func(groups []*flags.Group)
+	walk = func(groups []*flags.Group) {
+		for _, g := range groups {
+			for _, opt := range g.Options() {
+				switch opt.LongName {
+				case "", "help", "config", "config-profile":
+					continue
+				}
+				for _, line := range settingLines(opt.LongName, opt.Value()) {
+					fmt.Fprintln(w, line)
+				}
+			}
+			walk(g.Groups())
+		}
+	}
+	walk(parser.Groups())
+}
+
+func settingLines(name string, value interface{}) []string {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice:
+		out := []string{}
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, fmt.Sprintf("%s = %v", name, v.Index(i).Interface()))
+		}
+		return out
+	case reflect.Map:
+		keys := []string{}
+		for _, k := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(k.Interface()))
+		}
+		sort.Strings(keys)
+		out := []string{}
+		for _, k := range keys {
+			out = append(out, fmt.Sprintf("%s.%s = %v", name, k, v.MapIndex(reflect.ValueOf(k)).Interface()))
+		}
+		return out
+	default:
+		if d, ok := value.(time.Duration); ok {
+			return []string{fmt.Sprintf("%s = %s", name, d)}
+		}
+		if v.IsZero() {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s = %v", name, value)}
+	}
+}
+
+// loadTheme resolves a built in theme or one defined in the configuration
+// file, then applies any individual style and glyph overrides.
+func loadTheme(name string, file *settings.File, colours, glyphs map[string]string) (theme.Theme, error) {
+	if name == "" {
+		name = theme.DefaultName()
+	}
+
+	t, ok := theme.Builtin(name)
+	if !ok {
+		values, found := file.Theme(name)
+		if !found {
+			return t, fmt.Errorf("unknown theme %q", name)
+		}
+
+		baseName := "default"
+		for _, v := range values {
+			if v.Key == "base" {
+				baseName = v.Value
+			}
+		}
+		if t, ok = theme.Builtin(baseName); !ok {
+			return t, fmt.Errorf("theme %s: unknown base theme %q", name, baseName)
+		}
+		t.Name = name
+		for _, v := range values {
+			if v.Key == "base" {
+				continue
+			}
+			if err := t.Set(v.Key, v.Value); err != nil {
+				return t, fmt.Errorf("%s:%d: theme %s: %w", file.Path, v.Line, name, err)
+			}
+		}
+	}
+
+	for k, v := range colours {
+		if err := t.Set(k, v); err != nil {
+			return t, err
+		}
+	}
+	for k, v := range glyphs {
+		if err := t.Set("glyph."+k, v); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/simonrw/cflivestatus/deploy"
+)
+
+type deployCommand struct {
+	Stack          string            `long:"stack" required:"yes" description:"Name of the stack to create or update"`
+	Parameters     map[string]string `long:"parameter" description:"Template parameter, as name:value, may be repeated"`
+	ParametersFile string            `long:"parameters-file" description:"JSON file of template parameters, as an object or in the AWS CLI's list form"`
+	Tags           map[string]string `long:"tag" description:"Stack tag, as key:value, may be repeated"`
+	TagsFile       string            `long:"tags-file" description:"JSON file of stack tags, as an object or a list of Key/Value objects"`
+	Capabilities   []string          `long:"capability" description:"Capability to acknowledge, may be repeated" choice:"CAPABILITY_IAM" choice:"CAPABILITY_NAMED_IAM" choice:"CAPABILITY_AUTO_EXPAND"`
+	Yes            bool              `short:"y" long:"yes" description:"Execute the change set without asking"`
+	Args           struct {
+		Template string `required:"yes" positional-arg-name:"template"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// changeSetDeployer creates and executes change sets.
+type changeSetDeployer interface {
+	CreateChangeSet(ctx context.Context, in deploy.Input) (*deploy.ChangeSet, error)
+	Execute(ctx context.Context, cs *deploy.ChangeSet) error
+}
+
+// deploy creates a change set for the template, shows what it will change
+// and, once confirmed, executes it and watches the stack.
+func (a *app) deploy(ctx context.Context, d changeSetDeployer) error {
+	if a.controller == nil {
+		return fmt.Errorf("deploy changes the stack, so is disabled with --read-only")
+	}
+	cmd := a.opts.Deploy
+
+	in, err := cmd.input()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Creating change set for %s...\n", a.stackName)
+	cs, err := d.CreateChangeSet(ctx, in)
+	if errors.Is(err, deploy.ErrNoChanges) {
+		fmt.Println("No changes to deploy.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	writePreview(os.Stdout, a.stackName, cs)
+
+	if !cmd.Yes && !askYesNo(os.Stdin, os.Stdout, "Execute change set?") {
+		fmt.Printf("Change set %s has been left for review.\n", cs.Name)
+		return nil
+	}
+	if err := d.Execute(ctx, cs); err != nil {
+		return err
+	}
+	a.expectOperation = true
+	return a.watch(ctx)
+}
+
+// input gathers the template, parameters and tags, with values given on
+// the command line taking precedence over those from files.
+func (cmd deployCommand) input() (deploy.Input, error) {
+	body, err := os.ReadFile(cmd.Args.Template)
+	if err != nil {
+		return deploy.Input{}, fmt.Errorf("reading template: %w", err)
+	}
+	in := deploy.Input{
+		Template:   string(body),
+		Parameters: map[string]string{},
+		Tags:       map[string]string{},
+	}
+
+	if cmd.ParametersFile != "" {
+		if in.Parameters, err = readFile(cmd.ParametersFile, deploy.ReadParameters); err != nil {
+			return deploy.Input{}, err
+		}
+	}
+	for k, v := range cmd.Parameters {
+		in.Parameters[k] = v
+	}
+	if cmd.TagsFile != "" {
+		if in.Tags, err = readFile(cmd.TagsFile, deploy.ReadTags); err != nil {
+			return deploy.Input{}, err
+		}
+	}
+	for k, v := range cmd.Tags {
+		in.Tags[k] = v
+	}
+	for _, c := range cmd.Capabilities {
+		in.Capabilities = append(in.Capabilities, types.Capability(c))
+	}
+	return in, nil
+}
+
+func readFile(path string, read func(io.Reader) (map[string]string, error)) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return read(f)
+}
+
+// writePreview lists the changes a change set will make.
+func writePreview(w io.Writer, stackName string, cs *deploy.ChangeSet) {
+	verb := "update"
+	if cs.Type == types.ChangeSetTypeCreate {
+		verb = "create"
+	}
+	fmt.Fprintf(w, "Change set %s will %s %s:\n\n", cs.Name, verb, stackName)
+	if len(cs.Changes) == 0 {
+		fmt.Fprintln(w, "  no resource changes")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  ACTION\tRESOURCE\tTYPE\tREPLACEMENT")
+	for _, c := range cs.Changes {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", c.Action, c.Resource, c.Type, c.Replacement)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// askYesNo asks a question, treating anything other than yes as no.
+func askYesNo(r io.Reader, w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,270 @@
+// Package deploy creates and executes change sets.
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// maxTemplateBody is the largest template CloudFormation accepts inline,
+// rather than from S3.
+const maxTemplateBody = 51200
+
+// ErrNoChanges is returned when the template and parameters would not
+// change the stack.
+var ErrNoChanges = errors.New("no changes to deploy")
+
+// client is the interface that we consume from the AWS service.
+type client interface {
+	GetTemplateSummary(ctx context.Context, params *cloudformation.GetTemplateSummaryInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateSummaryOutput, error)
+	DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+	CreateChangeSet(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error)
+	DescribeChangeSet(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error)
+	ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error)
+	DeleteChangeSet(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error)
+}
+
+// Input is what to deploy.
+type Input struct {
+	Template     string
+	Parameters   map[string]string
+	Tags         map[string]string
+	Capabilities []types.Capability
+}
+
+// ChangeSet is a change set which is ready to execute.
+type ChangeSet struct {
+	ID      string
+	Name    string
+	Type    types.ChangeSetType
+	Changes []Change
+}
+
+// Change is a single resource change within a change set.
+type Change struct {
+	Action      types.ChangeAction
+	Resource    string
+	Type        string
+	Replacement types.Replacement
+}
+
+type deployer struct {
+	stackName string
+	client    client
+
+	// pollInterval is how often the change set is checked while it is
+	// being created.
+	pollInterval time.Duration
+}
+
+func New(stackName string, client client) *deployer {
+	return &deployer{
+		stackName:    stackName,
+		client:       client,
+		pollInterval: 2 * time.Second,
+	}
+}
+
+// CreateChangeSet creates a change set for the input and waits until it is
+// ready to execute. Parameters of an existing stack which are not given keep
+// their previous values. If there is nothing to change, the change set is
+// deleted and ErrNoChanges returned.
+func (d *deployer) CreateChangeSet(ctx context.Context, in Input) (*ChangeSet, error) {
+	if len(in.Template) > maxTemplateBody {
+		return nil, fmt.Errorf("template is %d bytes, larger than the %d bytes CloudFormation accepts without uploading it to S3; deploy it with a tool that uploads it, wrapped by run", len(in.Template), maxTemplateBody)
+	}
+	names, err := d.parameterNames(ctx, in.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	changeSetType, previous, err := d.existing(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &cloudformation.CreateChangeSetInput{
+		StackName:     aws.String(d.stackName),
+		ChangeSetName: aws.String(fmt.Sprintf("cflivestatus-%s", time.Now().UTC().Format("20060102150405"))),
+		ChangeSetType: changeSetType,
+		TemplateBody:  aws.String(in.Template),
+		Capabilities:  in.Capabilities,
+	}
+	for _, name := range names {
+		if v, ok := in.Parameters[name]; ok {
+			params.Parameters = append(params.Parameters, types.Parameter{ParameterKey: aws.String(name), ParameterValue: aws.String(v)})
+		} else if previous[name] {
+			params.Parameters = append(params.Parameters, types.Parameter{ParameterKey: aws.String(name), UsePreviousValue: aws.Bool(true)})
+		}
+	}
+	for name := range in.Parameters {
+		if !contains(names, name) {
+			return nil, fmt.Errorf("template has no parameter %q", name)
+		}
+	}
+	for _, k := range sortedKeys(in.Tags) {
+		params.Tags = append(params.Tags, types.Tag{Key: aws.String(k), Value: aws.String(in.Tags[k])})
+	}
+
+	res, err := d.client.CreateChangeSet(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("creating change set: %w", err)
+	}
+
+	cs := &ChangeSet{
+		ID:   deref(res.Id),
+		Name: *params.ChangeSetName,
+		Type: changeSetType,
+	}
+	if err := d.wait(ctx, cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// parameterNames asks CloudFormation for the names of the template's
+// parameters, which also checks that the template is valid.
+func (d *deployer) parameterNames(ctx context.Context, body string) ([]string, error) {
+	res, err := d.client.GetTemplateSummary(ctx, &cloudformation.GetTemplateSummaryInput{TemplateBody: aws.String(body)})
+	if err != nil {
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+	var names []string
+	for _, p := range res.Parameters {
+		names = append(names, deref(p.ParameterKey))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// existing returns whether the change set should create or update the stack,
+// and the names of the parameters of the existing stack.
+func (d *deployer) existing(ctx context.Context) (types.ChangeSetType, map[string]bool, error) {
+	res, err := d.client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(d.stackName)})
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") {
+			return types.ChangeSetTypeCreate, nil, nil
+		}
+		return "", nil, fmt.Errorf("describing stack: %w", err)
+	}
+	// a stack which has only ever had change sets created for it does not
+	// exist yet
+	if len(res.Stacks) == 0 || res.Stacks[0].StackStatus == types.StackStatusReviewInProgress {
+		return types.ChangeSetTypeCreate, nil, nil
+	}
+
+	previous := map[string]bool{}
+	for _, p := range res.Stacks[0].Parameters {
+		previous[deref(p.ParameterKey)] = true
+	}
+	return types.ChangeSetTypeUpdate, previous, nil
+}
+
+// wait polls the change set until it has been created, then reads its
+// changes.
+func (d *deployer) wait(ctx context.Context, cs *ChangeSet) error {
+	for {
+		params := &cloudformation.DescribeChangeSetInput{
+			StackName:     aws.String(d.stackName),
+			ChangeSetName: aws.String(cs.ID),
+		}
+		res, err := d.client.DescribeChangeSet(ctx, params)
+		if err != nil {
+			return fmt.Errorf("describing change set: %w", err)
+		}
+
+		switch res.Status {
+		case types.ChangeSetStatusCreateComplete:
+			cs.Changes = append(cs.Changes, changes(res.Changes)...)
+			for res.NextToken != nil {
+				params.NextToken = res.NextToken
+				res, err = d.client.DescribeChangeSet(ctx, params)
+				if err != nil {
+					return fmt.Errorf("describing change set: %w", err)
+				}
+				cs.Changes = append(cs.Changes, changes(res.Changes)...)
+			}
+			return nil
+		case types.ChangeSetStatusFailed:
+			reason := deref(res.StatusReason)
+			if strings.Contains(reason, "didn't contain changes") || strings.Contains(reason, "No updates are to be performed") {
+				if err := d.Delete(ctx, cs); err != nil {
+					return err
+				}
+				return ErrNoChanges
+			}
+			return fmt.Errorf("change set failed: %s", reason)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+// Execute starts the stack operation described by the change set.
+func (d *deployer) Execute(ctx context.Context, cs *ChangeSet) error {
+	params := &cloudformation.ExecuteChangeSetInput{
+		StackName:     aws.String(d.stackName),
+		ChangeSetName: aws.String(cs.ID),
+	}
+	if _, err := d.client.ExecuteChangeSet(ctx, params); err != nil {
+		return fmt.Errorf("executing change set: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a change set which will not be executed.
+func (d *deployer) Delete(ctx context.Context, cs *ChangeSet) error {
+	params := &cloudformation.DeleteChangeSetInput{
+		StackName:     aws.String(d.stackName),
+		ChangeSetName: aws.String(cs.ID),
+	}
+	if _, err := d.client.DeleteChangeSet(ctx, params); err != nil {
+		return fmt.Errorf("deleting change set: %w", err)
+	}
+	return nil
+}
+
+func changes(in []types.Change) []Change {
+	var out []Change
+	for _, c := range in {
+		rc := c.ResourceChange
+		if rc == nil {
+			continue
+		}
+		out = append(out, Change{
+			Action:      rc.Action,
+			Resource:    deref(rc.LogicalResourceId),
+			Type:        deref(rc.ResourceType),
+			Replacement: rc.Replacement,
+		})
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
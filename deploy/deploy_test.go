@@ -0,0 +1,344 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+)
+
+type summaryHandlerFunc func(ctx context.Context, params *cloudformation.GetTemplateSummaryInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateSummaryOutput, error)
+
+type stacksHandlerFunc func(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+
+type createHandlerFunc func(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error)
+
+type describeHandlerFunc func(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error)
+
+type executeHandlerFunc func(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error)
+
+type deleteHandlerFunc func(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error)
+
+type mockClient struct {
+	summaryFns []summaryHandlerFunc
+	summaryI   int
+
+	stackFns []stacksHandlerFunc
+	stackI   int
+
+	createFns []createHandlerFunc
+	createI   int
+
+	describeFns []describeHandlerFunc
+	describeI   int
+
+	executeFns []executeHandlerFunc
+	executeI   int
+
+	deleteFns []deleteHandlerFunc
+	deleteI   int
+}
+
+func (m *mockClient) GetTemplateSummary(ctx context.Context, params *cloudformation.GetTemplateSummaryInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateSummaryOutput, error) {
+	if m.summaryI >= len(m.summaryFns) {
+		panic("too few summary functions defined")
+	}
+	res, err := m.summaryFns[m.summaryI](ctx, params, optFns...)
+	m.summaryI++
+	return res, err
+}
+
+func (m *mockClient) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	if m.stackI >= len(m.stackFns) {
+		panic("too few stack functions defined")
+	}
+	res, err := m.stackFns[m.stackI](ctx, params, optFns...)
+	m.stackI++
+	return res, err
+}
+
+func (m *mockClient) CreateChangeSet(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+	if m.createI >= len(m.createFns) {
+		panic("too few create functions defined")
+	}
+	res, err := m.createFns[m.createI](ctx, params, optFns...)
+	m.createI++
+	return res, err
+}
+
+func (m *mockClient) DescribeChangeSet(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error) {
+	if m.describeI >= len(m.describeFns) {
+		panic("too few describe functions defined")
+	}
+	res, err := m.describeFns[m.describeI](ctx, params, optFns...)
+	m.describeI++
+	return res, err
+}
+
+func (m *mockClient) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+	if m.executeI >= len(m.executeFns) {
+		panic("too few execute functions defined")
+	}
+	res, err := m.executeFns[m.executeI](ctx, params, optFns...)
+	m.executeI++
+	return res, err
+}
+
+func (m *mockClient) DeleteChangeSet(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error) {
+	if m.deleteI >= len(m.deleteFns) {
+		panic("too few delete functions defined")
+	}
+	res, err := m.deleteFns[m.deleteI](ctx, params, optFns...)
+	m.deleteI++
+	return res, err
+}
+
+func (m *mockClient) assertNumFunctionsCalled(t *testing.T) {
+	if m.summaryI != len(m.summaryFns) {
+		t.Fatalf("too few summary function calls compared to setup, found %d expected %d", m.summaryI, len(m.summaryFns))
+	}
+	if m.stackI != len(m.stackFns) {
+		t.Fatalf("too few stack function calls compared to setup, found %d expected %d", m.stackI, len(m.stackFns))
+	}
+	if m.createI != len(m.createFns) {
+		t.Fatalf("too few create function calls compared to setup, found %d expected %d", m.createI, len(m.createFns))
+	}
+	if m.describeI != len(m.describeFns) {
+		t.Fatalf("too few describe function calls compared to setup, found %d expected %d", m.describeI, len(m.describeFns))
+	}
+	if m.executeI != len(m.executeFns) {
+		t.Fatalf("too few execute function calls compared to setup, found %d expected %d", m.executeI, len(m.executeFns))
+	}
+	if m.deleteI != len(m.deleteFns) {
+		t.Fatalf("too few delete function calls compared to setup, found %d expected %d", m.deleteI, len(m.deleteFns))
+	}
+}
+
+// summary describes a template with the given parameters.
+func summary(names ...string) summaryHandlerFunc {
+	return func(ctx context.Context, params *cloudformation.GetTemplateSummaryInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateSummaryOutput, error) {
+		out := &cloudformation.GetTemplateSummaryOutput{}
+		for _, name := range names {
+			out.Parameters = append(out.Parameters, types.ParameterDeclaration{ParameterKey: aws.String(name)})
+		}
+		return out, nil
+	}
+}
+
+func stackMissing(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	return nil, errors.New("Stack with id stack does not exist")
+}
+
+func created(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+	return &cloudformation.CreateChangeSetOutput{Id: aws.String("change-set-id")}, nil
+}
+
+func changeSetStatus(status types.ChangeSetStatus, reason string, changes ...types.Change) describeHandlerFunc {
+	return func(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error) {
+		return &cloudformation.DescribeChangeSetOutput{
+			Status:       status,
+			StatusReason: aws.String(reason),
+			Changes:      changes,
+		}, nil
+	}
+}
+
+func resourceChange(action types.ChangeAction, name, resourceType string) types.Change {
+	return types.Change{
+		Type: types.ChangeTypeResource,
+		ResourceChange: &types.ResourceChange{
+			Action:            action,
+			LogicalResourceId: aws.String(name),
+			ResourceType:      aws.String(resourceType),
+		},
+	}
+}
+
+const tmpl = `
+Parameters:
+  Env:
+    Type: String
+  Size:
+    Type: Number
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+`
+
+func TestCreateChangeSetForNewStack(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.summaryFns = append(client.summaryFns, summary("Size", "Env"))
+	client.stackFns = append(client.stackFns, stackMissing)
+	client.createFns = append(client.createFns, func(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+		is.Equal(*params.StackName, "stack")
+		is.Equal(params.ChangeSetType, types.ChangeSetTypeCreate)
+		is.Equal(*params.TemplateBody, tmpl)
+		is.Equal(params.Capabilities, []types.Capability{types.CapabilityCapabilityIam})
+		is.Equal(len(params.Parameters), 1)
+		is.Equal(*params.Parameters[0].ParameterKey, "Env")
+		is.Equal(*params.Parameters[0].ParameterValue, "prod")
+		is.Equal(len(params.Tags), 2)
+		is.Equal(*params.Tags[0].Key, "owner")
+		is.Equal(*params.Tags[1].Key, "team")
+		return created(ctx, params, optFns...)
+	})
+	client.describeFns = append(client.describeFns,
+		changeSetStatus(types.ChangeSetStatusCreatePending, ""),
+		changeSetStatus(types.ChangeSetStatusCreateComplete, "", resourceChange(types.ChangeActionAdd, "Bucket", "AWS::S3::Bucket")),
+	)
+	defer client.assertNumFunctionsCalled(t)
+
+	d := New("stack", client)
+	d.pollInterval = 0
+	cs, err := d.CreateChangeSet(context.Background(), Input{
+		Template:     tmpl,
+		Parameters:   map[string]string{"Env": "prod"},
+		Tags:         map[string]string{"team": "platform", "owner": "me"},
+		Capabilities: []types.Capability{types.CapabilityCapabilityIam},
+	})
+	is.NoErr(err)
+	is.Equal(cs.ID, "change-set-id")
+	is.Equal(cs.Type, types.ChangeSetTypeCreate)
+	is.Equal(cs.Changes, []Change{{Action: types.ChangeActionAdd, Resource: "Bucket", Type: "AWS::S3::Bucket"}})
+}
+
+func TestCreateChangeSetKeepsPreviousParameters(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.summaryFns = append(client.summaryFns, summary("Size", "Env"))
+	client.stackFns = append(client.stackFns, func(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+		return &cloudformation.DescribeStacksOutput{Stacks: []types.Stack{{
+			StackName:   aws.String("stack"),
+			StackStatus: types.StackStatusUpdateComplete,
+			Parameters: []types.Parameter{
+				{ParameterKey: aws.String("Env"), ParameterValue: aws.String("prod")},
+				{ParameterKey: aws.String("Removed"), ParameterValue: aws.String("x")},
+			},
+		}}}, nil
+	})
+	client.createFns = append(client.createFns, func(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+		is.Equal(params.ChangeSetType, types.ChangeSetTypeUpdate)
+		is.Equal(len(params.Parameters), 2)
+		is.Equal(*params.Parameters[0].ParameterKey, "Env")
+		is.True(*params.Parameters[0].UsePreviousValue)
+		is.Equal(*params.Parameters[1].ParameterKey, "Size")
+		is.Equal(*params.Parameters[1].ParameterValue, "3")
+		return created(ctx, params, optFns...)
+	})
+	client.describeFns = append(client.describeFns, changeSetStatus(types.ChangeSetStatusCreateComplete, ""))
+	defer client.assertNumFunctionsCalled(t)
+
+	d := New("stack", client)
+	_, err := d.CreateChangeSet(context.Background(), Input{Template: tmpl, Parameters: map[string]string{"Size": "3"}})
+	is.NoErr(err)
+}
+
+func TestCreateChangeSetWithoutChanges(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.summaryFns = append(client.summaryFns, summary("Size", "Env"))
+	client.stackFns = append(client.stackFns, stackMissing)
+	client.createFns = append(client.createFns, created)
+	client.describeFns = append(client.describeFns, changeSetStatus(types.ChangeSetStatusFailed, "The submitted information didn't contain changes. Submit different information to create a change set."))
+	client.deleteFns = append(client.deleteFns, func(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error) {
+		is.Equal(*params.ChangeSetName, "change-set-id")
+		return &cloudformation.DeleteChangeSetOutput{}, nil
+	})
+	defer client.assertNumFunctionsCalled(t)
+
+	d := New("stack", client)
+	_, err := d.CreateChangeSet(context.Background(), Input{Template: tmpl})
+	is.True(errors.Is(err, ErrNoChanges))
+}
+
+func TestCreateChangeSetFailed(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.summaryFns = append(client.summaryFns, summary("Size", "Env"))
+	client.stackFns = append(client.stackFns, stackMissing)
+	client.createFns = append(client.createFns, created)
+	client.describeFns = append(client.describeFns, changeSetStatus(types.ChangeSetStatusFailed, "Template format error"))
+	defer client.assertNumFunctionsCalled(t)
+
+	d := New("stack", client)
+	_, err := d.CreateChangeSet(context.Background(), Input{Template: tmpl})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "Template format error"))
+}
+
+func TestCreateChangeSetUnknownParameter(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.summaryFns = append(client.summaryFns, summary("Size", "Env"))
+	client.stackFns = append(client.stackFns, stackMissing)
+	defer client.assertNumFunctionsCalled(t)
+
+	d := New("stack", client)
+	_, err := d.CreateChangeSet(context.Background(), Input{Template: tmpl, Parameters: map[string]string{"Nope": "x"}})
+	is.True(err != nil)
+}
+
+func TestCreateChangeSetInvalidTemplate(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.summaryFns = append(client.summaryFns, func(ctx context.Context, params *cloudformation.GetTemplateSummaryInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateSummaryOutput, error) {
+		is.Equal(*params.TemplateBody, "not a template")
+		return nil, errors.New("Template format error")
+	})
+	defer client.assertNumFunctionsCalled(t)
+
+	d := New("stack", client)
+	_, err := d.CreateChangeSet(context.Background(), Input{Template: "not a template"})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "Template format error"))
+}
+
+func TestExecute(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.executeFns = append(client.executeFns, func(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+		is.Equal(*params.StackName, "stack")
+		is.Equal(*params.ChangeSetName, "change-set-id")
+		return &cloudformation.ExecuteChangeSetOutput{}, nil
+	})
+	defer client.assertNumFunctionsCalled(t)
+
+	d := New("stack", client)
+	is.NoErr(d.Execute(context.Background(), &ChangeSet{ID: "change-set-id"}))
+}
+
+func TestReadParameters(t *testing.T) {
+	is := is.New(t)
+
+	params, err := ReadParameters(strings.NewReader(`{"Env": "prod"}`))
+	is.NoErr(err)
+	is.Equal(params, map[string]string{"Env": "prod"})
+
+	params, err = ReadParameters(strings.NewReader(`[{"ParameterKey": "Env", "ParameterValue": "prod"}]`))
+	is.NoErr(err)
+	is.Equal(params, map[string]string{"Env": "prod"})
+
+	_, err = ReadParameters(strings.NewReader(`[{"Key": "Env"}]`))
+	is.True(err != nil)
+}
+
+func TestReadTags(t *testing.T) {
+	is := is.New(t)
+
+	tags, err := ReadTags(strings.NewReader(`[{"Key": "team", "Value": "platform"}]`))
+	is.NoErr(err)
+	is.Equal(tags, map[string]string{"team": "platform"})
+}
@@ -0,0 +1,64 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ReadParameters reads parameter values from JSON, either as an object of
+// names to values or in the list form used by the AWS CLI:
+//
+//	[{"ParameterKey": "Env", "ParameterValue": "prod"}]
+func ReadParameters(r io.Reader) (map[string]string, error) {
+	out, err := readPairs(r, "ParameterKey", "ParameterValue")
+	if err != nil {
+		return nil, fmt.Errorf("reading parameters: %w", err)
+	}
+	return out, nil
+}
+
+// ReadTags reads tags from JSON, either as an object of keys to values or
+// as a list of {"Key": ..., "Value": ...} objects.
+func ReadTags(r io.Reader) (map[string]string, error) {
+	out, err := readPairs(r, "Key", "Value")
+	if err != nil {
+		return nil, fmt.Errorf("reading tags: %w", err)
+	}
+	return out, nil
+}
+
+func readPairs(r io.Reader, keyField, valueField string) (map[string]string, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	out := map[string]string{}
+	if err := json.Unmarshal(raw, &out); err == nil {
+		return out, nil
+	}
+
+	var list []map[string]string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("expected an object of strings or a list of %s/%s objects", keyField, valueField)
+	}
+	for _, item := range list {
+		key, ok := item[keyField]
+		if !ok {
+			return nil, fmt.Errorf("entry is missing %s", keyField)
+		}
+		out[key] = item[valueField]
+	}
+	return out, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
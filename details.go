@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/theme"
+)
+
+// detailEvents is the most events shown for the selected resource.
+const detailEvents = 10
+
+// SetPaths sets the construct paths shown in place of logical IDs.
+func (s *Screen) SetPaths(paths map[string]string) {
+	s.paths = paths
+}
+
+// name returns the label for a resource: its construct path if it is known,
+// otherwise its logical ID.
+func (s *Screen) name(logicalID string) string {
+	if p, ok := s.paths[logicalID]; ok && p != "" {
+		return p
+	}
+	return logicalID
+}
+
+// MoveCursor moves the selection up or down the resource table, selecting
+// the first row if nothing is selected yet, or through the history.
+func (s *Screen) MoveCursor(delta int) {
+	if s.view == historyView {
+		s.history.move(delta)
+		return
+	}
+	if len(s.rows) == 0 {
+		return
+	}
+	n := -1
+	for i, id := range s.rows {
+		if id == s.selected {
+			n = i
+			break
+		}
+	}
+	if n < 0 {
+		s.selected = s.rows[0]
+		return
+	}
+	n = min(max(n+delta, 0), len(s.rows)-1)
+	s.selected = s.rows[n]
+}
+
+// ToggleDetails shows or hides the details of the selected resource, or in
+// the history opens or closes the selected operation.
+func (s *Screen) ToggleDetails() error {
+	if s.view == historyView {
+		s.history.toggleOpen()
+		return nil
+	}
+	if s.view == detailView {
+		s.view = resourcesView
+		return nil
+	}
+	if s.selected == "" {
+		if len(s.rows) == 0 {
+			return errors.New("no resource to show")
+		}
+		s.selected = s.rows[0]
+	}
+	s.view = detailView
+	return nil
+}
+
+// renderDetail draws everything known about the selected resource,
+// including its raw logical ID, and its most recent events.
+func (s *Screen) renderDetail(i int, snap *fetcher.Snapshot) {
+	style := s.theme.Style(theme.Default)
+	s.write(i, style, "Details%s", hints(s.keys.hint("return to resources", actionDetails), s.keys.hint("change resource", actionUp, actionDown)))
+	i += 2
+
+	var r *fetcher.StackResource
+	for n := range snap.Resources {
+		if snap.Resources[n].Resource == s.selected {
+			r = &snap.Resources[n]
+			break
+		}
+	}
+
+	field := func(name, value string) {
+		if value == "" {
+			return
+		}
+		col := s.writeAt(i, 0, style.Bold(true), "%-16s", name+":")
+		s.writeAt(i, col, style, "%s", value)
+		i++
+	}
+	field("Logical ID", s.selected)
+	if p := s.paths[s.selected]; p != "" {
+		field("Construct path", p)
+	}
+	if r == nil {
+		s.write(i, s.theme.Style(theme.Skipped), "no longer in the stack")
+		return
+	}
+	category := resourceCategory(r.Status)
+	field("Type", r.Type)
+	col := s.writeAt(i, 0, style.Bold(true), "%-16s", "Status:")
+	s.writeAt(i, col, s.theme.Style(category), "%s%s", s.glyph(category), r.Status)
+	i++
+	field("Physical ID", r.PhysicalID)
+	if !r.Timestamp.IsZero() {
+		field("Updated", r.Timestamp.Local().Format("2006-01-02 15:04:05"))
+	}
+	if r.Reason != "" {
+		s.writeAt(i, 0, style.Bold(true), "Reason:")
+		i = s.writeWrapped(i, 16, s.theme.Style(category), r.Reason)
+	}
+	for _, h := range r.Hooks {
+		category := hookCategory(h.Status)
+		s.writeAt(i, 0, style.Bold(true), "Hook:")
+		s.writeAt(i, 16, s.theme.Style(category), "%s%s %s [%s] %s", s.glyph(category), h.Type, h.Status, h.FailureMode, h.Reason)
+		i++
+	}
+
+	i++
+	s.write(i, style.Bold(true), "Recent events")
+	i++
+	shown := 0
+	for _, e := range snap.Events {
+		if e.Resource != s.selected || e.Hook != nil {
+			continue
+		}
+		category := resourceCategory(e.Status)
+		if e.Reason != "" {
+			s.write(i, s.theme.Style(category), "  %s %s%s (%s)", e.Timestamp.Local().Format("15:04:05"), s.glyph(category), e.Status, e.Reason)
+		} else {
+			s.write(i, s.theme.Style(category), "  %s %s%s", e.Timestamp.Local().Format("15:04:05"), s.glyph(category), e.Status)
+		}
+		i++
+		if shown++; shown == detailEvents {
+			break
+		}
+	}
+	if shown == 0 {
+		s.write(i, style, "  none")
+	}
+}
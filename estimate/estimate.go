@@ -0,0 +1,186 @@
+// Package estimate predicts how long the resources of a stack will take to
+// settle, from how long they took in its earlier operations.
+package estimate
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+	"github.com/simonrw/cflivestatus/store"
+)
+
+// Confidence is how far an estimate can be relied on.
+type Confidence int
+
+const (
+	// Unknown means there was nothing to base an estimate on.
+	Unknown Confidence = iota
+	// Low estimates are based on other resources of the same type.
+	Low
+	// Medium estimates are based on one or two earlier durations of the
+	// resource itself.
+	Medium
+	// High estimates are based on several earlier durations of the
+	// resource itself.
+	High
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case Low:
+		return "low"
+	case Medium:
+		return "medium"
+	case High:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// highSamples is how many earlier durations of a resource give its
+// estimate high confidence.
+const highSamples = 3
+
+// key identifies durations by what was done, e.g. CREATE, and to what: a
+// logical ID or a resource type.
+type key struct {
+	action string
+	of     string
+}
+
+// Estimator predicts durations from earlier operations on a stack.
+type Estimator struct {
+	byName map[key][]time.Duration
+	byType map[key][]time.Duration
+}
+
+// New learns from records. Only operations which succeeded are used, and
+// within them only the resources which succeeded, as failures and rollbacks
+// cut durations short or stretch them out.
+func New(records []store.Record) *Estimator {
+	e := &Estimator{byName: map[key][]time.Duration{}, byType: map[key][]time.Duration{}}
+	for _, rec := range records {
+		if status.Stack(rec.Status) != status.Success {
+			continue
+		}
+		for _, r := range rec.Resources {
+			if r.Duration <= 0 || status.Resource(r.Status) != status.Success {
+				continue
+			}
+			a := action(r.Status)
+			e.byName[key{a, r.Name}] = append(e.byName[key{a, r.Name}], r.Duration)
+			e.byType[key{a, r.Type}] = append(e.byType[key{a, r.Type}], r.Duration)
+		}
+	}
+	return e
+}
+
+// Duration estimates how long a resource takes to do what its status says
+// it is doing, from the median of its own earlier durations or, failing
+// that, of those of other resources of its type. A nil Estimator knows
+// nothing.
+func (e *Estimator) Duration(name, typ string, s types.ResourceStatus) (time.Duration, Confidence) {
+	if e == nil {
+		return 0, Unknown
+	}
+	a := action(s)
+	if d := e.byName[key{a, name}]; len(d) > 0 {
+		if len(d) >= highSamples {
+			return store.Median(d), High
+		}
+		return store.Median(d), Medium
+	}
+	if d := e.byType[key{a, typ}]; len(d) > 0 {
+		return store.Median(d), Low
+	}
+	return 0, Unknown
+}
+
+// Remaining estimates how much longer an in progress resource will take,
+// given that it has been in its status since its timestamp. A resource
+// which has already taken longer than expected has no time left.
+func (e *Estimator) Remaining(r fetcher.StackResource, now time.Time) (time.Duration, Confidence) {
+	d, c := e.Duration(r.Resource, r.Type, r.Status)
+	if c == Unknown {
+		return 0, Unknown
+	}
+	return max(d-now.Sub(r.Timestamp), 0), c
+}
+
+// Operation estimates how much longer the operation in progress in snap
+// will take. Resources yet to start are assumed to start once everything
+// they depend on in the template has finished, so the estimate follows the
+// longest chain of dependencies rather than adding every resource up. It
+// returns false if nothing is known about any resource still to finish.
+// The confidence is the lowest of those resources, and low if any of them
+// could not be estimated at all.
+func (e *Estimator) Operation(snap *fetcher.Snapshot, now time.Time) (time.Duration, Confidence, bool) {
+	resources := make(map[string]fetcher.StackResource, len(snap.Resources))
+	for _, r := range snap.Resources {
+		resources[r.Resource] = r
+	}
+
+	confidence := High
+	known := false
+	finish := map[string]time.Duration{}
+	var finishing func(name string) time.Duration
+	finishing = func(name string) time.Duration {
+		if d, ok := finish[name]; ok {
+			return d
+		}
+		// guards against cycles in the dependencies
+		finish[name] = 0
+		r, ok := resources[name]
+		if !ok {
+			return 0
+		}
+
+		var d time.Duration
+		var c Confidence
+		switch category := status.Resource(r.Status); {
+		case category == status.Pending:
+			var start time.Duration
+			if snap.Template != nil {
+				for _, dep := range snap.Template.Resources[name].DependsOn {
+					start = max(start, finishing(dep))
+				}
+			}
+			d, c = e.Duration(r.Resource, r.Type, r.Status)
+			d += start
+		case category.Active():
+			d, c = e.Remaining(r, now)
+		default:
+			return 0
+		}
+
+		if c == Unknown {
+			confidence = Low
+		} else {
+			known = true
+			confidence = min(confidence, c)
+		}
+		finish[name] = d
+		return d
+	}
+
+	var longest time.Duration
+	for name := range resources {
+		longest = max(longest, finishing(name))
+	}
+	return longest, confidence, known
+}
+
+// action returns what a status says is being done to a resource, such as
+// CREATE or DELETE. Resources which are still pending are yet to be
+// created.
+func action(s types.ResourceStatus) string {
+	if s == status.ResourcePending {
+		return "CREATE"
+	}
+	a, _, _ := strings.Cut(string(s), "_")
+	return a
+}
@@ -0,0 +1,107 @@
+package estimate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+	"github.com/simonrw/cflivestatus/store"
+	"github.com/simonrw/cflivestatus/template"
+)
+
+var now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func record(stack types.StackStatus, resources ...store.Resource) store.Record {
+	return store.Record{Stack: "app", Status: stack, Resources: resources}
+}
+
+func created(name, typ string, d time.Duration) store.Resource {
+	return store.Resource{Name: name, Type: typ, Status: types.ResourceStatusCreateComplete, Duration: d}
+}
+
+func TestDuration(t *testing.T) {
+	is := is.New(t)
+
+	e := New([]store.Record{
+		record(types.StackStatusCreateComplete, created("Cdn", "AWS::CloudFront::Distribution", 10*time.Minute), created("Db", "AWS::RDS::DBInstance", 8*time.Minute)),
+		record(types.StackStatusCreateComplete, created("Cdn", "AWS::CloudFront::Distribution", 12*time.Minute)),
+		record(types.StackStatusCreateComplete, created("Cdn", "AWS::CloudFront::Distribution", 11*time.Minute)),
+		// failed operations are not learnt from
+		record(types.StackStatusRollbackComplete, created("Db", "AWS::RDS::DBInstance", time.Minute)),
+	})
+
+	d, c := e.Duration("Cdn", "AWS::CloudFront::Distribution", types.ResourceStatusCreateInProgress)
+	is.Equal(d, 11*time.Minute)
+	is.Equal(c, High)
+
+	d, c = e.Duration("Db", "AWS::RDS::DBInstance", types.ResourceStatusCreateInProgress)
+	is.Equal(d, 8*time.Minute)
+	is.Equal(c, Medium)
+
+	// another resource of a known type
+	d, c = e.Duration("Replica", "AWS::RDS::DBInstance", status.ResourcePending)
+	is.Equal(d, 8*time.Minute)
+	is.Equal(c, Low)
+
+	// deleting is not creating
+	_, c = e.Duration("Cdn", "AWS::CloudFront::Distribution", types.ResourceStatusDeleteInProgress)
+	is.Equal(c, Unknown)
+
+	var none *Estimator
+	_, c = none.Duration("Cdn", "AWS::CloudFront::Distribution", types.ResourceStatusCreateInProgress)
+	is.Equal(c, Unknown)
+}
+
+func TestRemaining(t *testing.T) {
+	is := is.New(t)
+
+	e := New([]store.Record{record(types.StackStatusCreateComplete, created("Db", "AWS::RDS::DBInstance", 8*time.Minute))})
+	r := fetcher.StackResource{Resource: "Db", Type: "AWS::RDS::DBInstance", Status: types.ResourceStatusCreateInProgress, Timestamp: now.Add(-5 * time.Minute)}
+
+	d, c := e.Remaining(r, now)
+	is.Equal(d, 3*time.Minute)
+	is.Equal(c, Medium)
+
+	// running over the estimate leaves nothing
+	d, _ = e.Remaining(r, now.Add(time.Hour))
+	is.Equal(d, time.Duration(0))
+}
+
+func TestOperation(t *testing.T) {
+	is := is.New(t)
+
+	e := New([]store.Record{
+		record(types.StackStatusCreateComplete,
+			created("Bucket", "AWS::S3::Bucket", 10*time.Second),
+			created("Db", "AWS::RDS::DBInstance", 8*time.Minute),
+			created("Cdn", "AWS::CloudFront::Distribution", 10*time.Minute),
+		),
+	})
+	snap := &fetcher.Snapshot{
+		Resources: []fetcher.StackResource{
+			{Resource: "Bucket", Type: "AWS::S3::Bucket", Status: types.ResourceStatusCreateComplete},
+			{Resource: "Db", Type: "AWS::RDS::DBInstance", Status: types.ResourceStatusCreateInProgress, Timestamp: now.Add(-2 * time.Minute)},
+			{Resource: "Cdn", Type: "AWS::CloudFront::Distribution", Status: status.ResourcePending},
+			{Resource: "Dns", Type: "AWS::Route53::RecordSet", Status: status.ResourcePending},
+		},
+		Template: &template.Template{Resources: map[string]template.Resource{
+			"Bucket": {},
+			"Db":     {},
+			"Cdn":    {DependsOn: []string{"Bucket", "Db"}},
+			"Dns":    {DependsOn: []string{"Cdn"}},
+		}},
+	}
+
+	// the distribution waits for the database, with 6m to go, and takes
+	// 10m itself; nothing is known about the record set
+	d, c, ok := e.Operation(snap, now)
+	is.True(ok)
+	is.Equal(d, 16*time.Minute)
+	is.Equal(c, Low)
+
+	_, _, ok = New(nil).Operation(snap, now)
+	is.True(!ok)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/estimate"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+	"github.com/simonrw/cflivestatus/store"
+)
+
+// loadEstimates learns how long the stack's resources take from the
+// history store and the finished operations in its event log, in the
+// background, sending what it learnt on results. Operations from the event
+// log are only added to the store when recording.
+func (a *app) loadEstimates(ctx context.Context, results chan<- *estimate.Estimator) {
+	go func() {
+		records, err := store.Open(a.historyDir()).Load(a.stackName)
+		if err != nil {
+			log.Warn().Err(err).Msg("could not read the history store")
+		}
+		recent, err := finishedOperations(ctx, a.history)
+		if err != nil {
+			log.Warn().Err(err).Msg("could not read past operations for estimates")
+		}
+		records, _ = store.Merge(records, recent...)
+		if a.recorder != nil && len(recent) > 0 {
+			if _, err := a.recorder.store.Save(recent...); err != nil {
+				log.Warn().Err(err).Msg("could not record past operations")
+			}
+		}
+		log.Debug().Int("operations", len(records)).Msg("loaded estimates")
+		results <- estimate.New(records)
+	}()
+}
+
+// SetEstimator sets what estimates how long resources will take.
+func (s *Screen) SetEstimator(e *estimate.Estimator) {
+	s.estimator = e
+}
+
+// operationETA describes when the operation in progress is expected to
+// finish, or returns nothing if there is no operation or no estimate.
+func (s *Screen) operationETA(snap *fetcher.Snapshot, now time.Time) string {
+	if snap == nil || !status.Stack(snap.Stack.Status).Active() {
+		return ""
+	}
+	left, c, ok := s.estimator.Operation(snap, now)
+	if !ok {
+		return ""
+	}
+	if left == 0 {
+		return fmt.Sprintf("ETA any moment %s %s confidence", confidenceMeter(c), c)
+	}
+	return fmt.Sprintf("ETA %s in ~%s %s %s confidence", now.Add(left).Format("15:04:05"), formatAge(left), confidenceMeter(c), c)
+}
+
+// resourceETA describes how much longer an in progress resource, shown
+// under another name, is expected to take, or returns nothing if it cannot
+// be estimated.
+func (s *Screen) resourceETA(logicalID string, r fetcher.StackResource, now time.Time) string {
+	if !status.Resource(r.Status).Active() {
+		return ""
+	}
+	r.Resource = logicalID
+	left, c := s.estimator.Remaining(r, now)
+	switch {
+	case c == estimate.Unknown:
+		return ""
+	case left == 0:
+		return fmt.Sprintf("  [past estimate %s]", confidenceMeter(c))
+	default:
+		return fmt.Sprintf("  [~%s left %s]", formatAge(left), confidenceMeter(c))
+	}
+}
+
+// confidenceMeter shows a confidence level as a row of filled and empty
+// dots.
+func confidenceMeter(c estimate.Confidence) string {
+	n := int(c)
+	return strings.Repeat("●", n) + strings.Repeat("○", int(estimate.High)-n)
+}
@@ -0,0 +1,103 @@
+// Package failure finds the failures which caused a stack operation to fail,
+// among the many cancellations that follow them.
+package failure
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// RootCause is a failure which was not caused by another, along with the
+// resources cancelled as a result.
+type RootCause struct {
+	Event     fetcher.StackEvent
+	Cancelled []fetcher.StackEvent
+}
+
+// Cancelled returns whether a failure reason says that the resource was
+// only cancelled because something else failed.
+func Cancelled(reason string) bool {
+	return strings.Contains(strings.ToLower(reason), "cancelled")
+}
+
+// Analyse finds the root causes of failure in the most recent operation
+// recorded in events, which are newest first as CloudFormation returns them.
+// Root causes are the genuine failures before the stack started rolling
+// back, or the earliest genuine failure if there were none.
+func Analyse(events []fetcher.StackEvent) []RootCause {
+	op := CurrentOperation(events)
+
+	var failures, cancellations []fetcher.StackEvent
+	var rollbackStarted *fetcher.StackEvent
+	seen := map[string]bool{}
+	for i := range op {
+		e := op[i]
+		if e.StackLevel() {
+			if rollbackStarted == nil && status.Resource(e.Status) == status.RollingBack {
+				rollbackStarted = &op[i]
+			}
+			continue
+		}
+		if e.Hook != nil || status.Resource(e.Status) != status.Failed {
+			continue
+		}
+		if Cancelled(e.Reason) {
+			// only the first cancellation of each resource is interesting
+			if !seen[e.Resource] {
+				seen[e.Resource] = true
+				cancellations = append(cancellations, e)
+			}
+			continue
+		}
+		failures = append(failures, e)
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+
+	var causes []RootCause
+	for _, f := range failures {
+		if rollbackStarted == nil || !f.Timestamp.After(rollbackStarted.Timestamp) {
+			causes = append(causes, RootCause{Event: f})
+		}
+	}
+	if len(causes) == 0 {
+		causes = []RootCause{{Event: failures[0]}}
+	}
+
+	// each cancellation is put down to the latest root cause before it
+	for _, c := range cancellations {
+		n := 0
+		for i, cause := range causes {
+			if !cause.Event.Timestamp.After(c.Timestamp) {
+				n = i
+			}
+		}
+		causes[n].Cancelled = append(causes[n].Cancelled, c)
+	}
+	for _, cause := range causes {
+		sort.SliceStable(cause.Cancelled, func(i, j int) bool { return cause.Cancelled[i].Resource < cause.Cancelled[j].Resource })
+	}
+	return causes
+}
+
+// CurrentOperation returns the events since the most recent operation on the
+// stack started, oldest first.
+func CurrentOperation(events []fetcher.StackEvent) []fetcher.StackEvent {
+	start := len(events)
+	for i, e := range events {
+		if e.StartsOperation() {
+			start = i + 1
+			break
+		}
+	}
+
+	out := make([]fetcher.StackEvent, 0, start)
+	for i := start - 1; i >= 0; i-- {
+		out = append(out, events[i])
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package failure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// history builds events from oldest to newest, returning them newest first
+// as CloudFormation does.
+type history []fetcher.StackEvent
+
+func (h *history) stack(after time.Duration, s types.ResourceStatus) {
+	*h = append(*h, fetcher.StackEvent{
+		StackID:    "stack-id",
+		PhysicalID: "stack-id",
+		Resource:   "stack",
+		Status:     s,
+		Timestamp:  start.Add(after),
+	})
+}
+
+func (h *history) resource(after time.Duration, name string, s types.ResourceStatus, reason string) {
+	*h = append(*h, fetcher.StackEvent{
+		StackID:   "stack-id",
+		Resource:  name,
+		Status:    s,
+		Reason:    reason,
+		Timestamp: start.Add(after),
+	})
+}
+
+func (h history) events() []fetcher.StackEvent {
+	out := make([]fetcher.StackEvent, 0, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		out = append(out, h[i])
+	}
+	return out
+}
+
+func TestNoFailures(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	h.stack(0, types.ResourceStatusCreateInProgress)
+	h.resource(time.Second, "Bucket", types.ResourceStatusCreateComplete, "")
+	h.stack(2*time.Second, types.ResourceStatusCreateComplete)
+
+	is.Equal(len(Analyse(h.events())), 0)
+}
+
+func TestRootCauseWithCancellations(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	h.stack(0, types.ResourceStatusCreateInProgress)
+	h.resource(1*time.Second, "Queue", types.ResourceStatusCreateInProgress, "")
+	h.resource(1*time.Second, "Bucket", types.ResourceStatusCreateInProgress, "")
+	h.resource(1*time.Second, "Table", types.ResourceStatusCreateInProgress, "")
+	h.resource(2*time.Second, "Bucket", types.ResourceStatusCreateFailed, "bucket already exists")
+	h.resource(3*time.Second, "Table", types.ResourceStatusCreateFailed, "Resource creation cancelled")
+	h.resource(3*time.Second, "Queue", types.ResourceStatusCreateFailed, "Resource creation cancelled")
+	h.stack(4*time.Second, types.ResourceStatusRollbackInProgress)
+	h.resource(5*time.Second, "Table", types.ResourceStatusDeleteFailed, "table is in use")
+
+	causes := Analyse(h.events())
+	is.Equal(len(causes), 1)
+	is.Equal(causes[0].Event.Resource, "Bucket")
+	is.Equal(causes[0].Event.Reason, "bucket already exists")
+	is.Equal(len(causes[0].Cancelled), 2)
+	is.Equal(causes[0].Cancelled[0].Resource, "Queue")
+	is.Equal(causes[0].Cancelled[1].Resource, "Table")
+}
+
+func TestOnlyCurrentOperation(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	h.stack(0, types.ResourceStatusUpdateInProgress)
+	h.resource(1*time.Second, "Old", types.ResourceStatusUpdateFailed, "an earlier failure")
+	h.stack(2*time.Second, types.ResourceStatusUpdateRollbackComplete)
+	h.stack(10*time.Second, types.ResourceStatusUpdateInProgress)
+	h.resource(11*time.Second, "New", types.ResourceStatusUpdateFailed, "invalid property")
+	h.resource(12*time.Second, "Other", types.ResourceStatusUpdateFailed, "Resource update cancelled")
+
+	causes := Analyse(h.events())
+	is.Equal(len(causes), 1)
+	is.Equal(causes[0].Event.Resource, "New")
+	is.Equal(len(causes[0].Cancelled), 1)
+}
+
+func TestConcurrentRootCauses(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	h.stack(0, types.ResourceStatusCreateInProgress)
+	h.resource(1*time.Second, "Role", types.ResourceStatusCreateFailed, "access denied")
+	h.resource(2*time.Second, "Queue", types.ResourceStatusCreateFailed, "Resource creation cancelled")
+	h.resource(3*time.Second, "Key", types.ResourceStatusCreateFailed, "limit exceeded")
+	h.resource(4*time.Second, "Topic", types.ResourceStatusCreateFailed, "Resource creation cancelled")
+	h.stack(5*time.Second, types.ResourceStatusRollbackInProgress)
+
+	causes := Analyse(h.events())
+	is.Equal(len(causes), 2)
+	is.Equal(causes[0].Event.Resource, "Role")
+	is.Equal(causes[0].Cancelled[0].Resource, "Queue")
+	is.Equal(causes[1].Event.Resource, "Key")
+	is.Equal(causes[1].Cancelled[0].Resource, "Topic")
+}
+
+func TestRollbackFailureOnly(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	h.stack(0, types.ResourceStatusUpdateInProgress)
+	h.stack(1*time.Second, types.ResourceStatusUpdateRollbackInProgress)
+	h.resource(2*time.Second, "Function", types.ResourceStatusUpdateFailed, "code not found")
+
+	causes := Analyse(h.events())
+	is.Equal(len(causes), 1)
+	is.Equal(causes[0].Event.Resource, "Function")
+}
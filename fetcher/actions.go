@@ -0,0 +1,33 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// CancelUpdate cancels the update in progress, which rolls the stack back.
+func (f *fetcher) CancelUpdate(ctx context.Context) error {
+	params := &cloudformation.CancelUpdateStackInput{
+		StackName: aws.String(f.stackName),
+	}
+	if _, err := f.client.CancelUpdateStack(ctx, params); err != nil {
+		return fmt.Errorf("cancelling update: %w", err)
+	}
+	return nil
+}
+
+// ContinueRollback resumes a rollback which failed, skipping the given
+// resources, which are then left as they are.
+func (f *fetcher) ContinueRollback(ctx context.Context, skip []string) error {
+	params := &cloudformation.ContinueUpdateRollbackInput{
+		StackName:       aws.String(f.stackName),
+		ResourcesToSkip: skip,
+	}
+	if _, err := f.client.ContinueUpdateRollback(ctx, params); err != nil {
+		return fmt.Errorf("continuing rollback: %w", err)
+	}
+	return nil
+}
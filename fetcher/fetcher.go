@@ -2,21 +2,41 @@ package fetcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/status"
+	"github.com/simonrw/cflivestatus/template"
 )
 
+// ErrStackNotFound is returned when the stack does not exist, or no longer
+// exists.
+var ErrStackNotFound = errors.New("stack not found")
+
 type fetcher struct {
 	stackName string
 	client    client
+
+	// the template is only fetched again when a new operation starts, or
+	// until fetching it succeeds
+	template    *template.Template
+	templateKey string
+	// templateWarned is the operation a failure to fetch the template has
+	// been logged for, so that retries do not repeat it.
+	templateWarned string
 }
 
 func New(stackName string, client client) *fetcher {
 	return &fetcher{
-		stackName,
-		client,
+		stackName: stackName,
+		client:    client,
 	}
 }
 
@@ -39,13 +59,248 @@ func (f *fetcher) Fetch(ctx context.Context) ([]StackResource, error) {
 		if r.ResourceStatusReason != nil {
 			reason = *r.ResourceStatusReason
 		}
+		timestamp := time.Time{}
+		if r.Timestamp != nil {
+			timestamp = *r.Timestamp
+		}
 
 		out = append(out, StackResource{
-			Resource: resource,
-			Status:   r.ResourceStatus,
-			Reason:   reason,
+			Resource:   resource,
+			Type:       deref(r.ResourceType),
+			PhysicalID: deref(r.PhysicalResourceId),
+			Status:     r.ResourceStatus,
+			Reason:     reason,
+			Timestamp:  timestamp,
 		})
 	}
 
 	return out, nil
 }
+
+// Stack returns the stack level status.
+func (f *fetcher) Stack(ctx context.Context) (*Stack, error) {
+	params := &cloudformation.DescribeStacksInput{
+		StackName: aws.String(f.stackName),
+	}
+	res, err := f.client.DescribeStacks(ctx, params)
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && strings.Contains(apiErr.ErrorMessage(), "does not exist") {
+		return nil, fmt.Errorf("%w: %s", ErrStackNotFound, f.stackName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("describing stack: %w", err)
+	}
+	if len(res.Stacks) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrStackNotFound, f.stackName)
+	}
+
+	stack := newStack(res.Stacks[0])
+	return &stack, nil
+}
+
+// Events returns the most recent page of stack events, newest first.
+func (f *fetcher) Events(ctx context.Context) ([]StackEvent, error) {
+	return f.StackEvents(ctx, f.stackName)
+}
+
+// StackEvents returns the most recent page of events of another stack, such
+// as one nested within the stack being watched, newest first.
+func (f *fetcher) StackEvents(ctx context.Context, stack string) ([]StackEvent, error) {
+	params := &cloudformation.DescribeStackEventsInput{
+		StackName: aws.String(stack),
+	}
+	res, err := f.client.DescribeStackEvents(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("describing stack events: %w", err)
+	}
+
+	out := []StackEvent{}
+	for _, e := range res.StackEvents {
+		out = append(out, newStackEvent(e))
+	}
+	return out, nil
+}
+
+// maxHistoryPages limits how much of a long lived stack's event log History
+// reads, at 100 events a page.
+const maxHistoryPages = 50
+
+// History returns the stack's event log, newest first, as far back as
+// maxHistoryPages allows.
+func (f *fetcher) History(ctx context.Context) ([]StackEvent, error) {
+	params := &cloudformation.DescribeStackEventsInput{
+		StackName: aws.String(f.stackName),
+	}
+	out := []StackEvent{}
+	for page := 0; page < maxHistoryPages; page++ {
+		res, err := f.client.DescribeStackEvents(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("describing stack events: %w", err)
+		}
+		for _, e := range res.StackEvents {
+			out = append(out, newStackEvent(e))
+		}
+		if res.NextToken == nil {
+			break
+		}
+		params.NextToken = res.NextToken
+	}
+	return out, nil
+}
+
+// Template returns the stack's template, after any transforms have been
+// applied.
+func (f *fetcher) Template(ctx context.Context) (*template.Template, error) {
+	params := &cloudformation.GetTemplateInput{
+		StackName:     aws.String(f.stackName),
+		TemplateStage: types.TemplateStageProcessed,
+	}
+	res, err := f.client.GetTemplate(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("getting template: %w", err)
+	}
+	t, err := template.Parse(deref(res.TemplateBody))
+	if err != nil {
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+	return t, nil
+}
+
+// currentTemplate returns the template for the stack's current operation,
+// fetching it when a new operation has started. Failing to fetch it is not
+// fatal: the stack can still be watched without it, and it is fetched again
+// on the next poll.
+func (f *fetcher) currentTemplate(ctx context.Context, stack *Stack) *template.Template {
+	key := fmt.Sprintf("%s %s %s", stack.ID, stack.Created, stack.LastUpdated)
+	if key == f.templateKey {
+		return f.template
+	}
+
+	t, err := f.Template(ctx)
+	if err != nil {
+		if f.templateWarned != key {
+			log.Warn().Err(err).Msg("resources which have not started will not be shown")
+			f.templateWarned = key
+		}
+		return nil
+	}
+	f.template = t
+	f.templateKey = key
+	return t
+}
+
+// pendingResources returns the resources in the template which are not yet
+// part of the stack, while the stack is being created or updated. Resources
+// with a condition are left out as they may never be created.
+func pendingResources(t *template.Template, stack *Stack, resources []StackResource) []StackResource {
+	if t == nil || status.Stack(stack.Status) != status.InProgress || stack.Status == types.StackStatusDeleteInProgress {
+		return nil
+	}
+	present := map[string]types.ResourceStatus{}
+	for _, r := range resources {
+		present[r.Resource] = r.Status
+	}
+	var out []StackResource
+	for _, name := range t.Names() {
+		r := t.Resources[name]
+		if _, ok := present[name]; ok || r.Condition != "" {
+			continue
+		}
+		pending := StackResource{
+			Resource: name,
+			Type:     r.Type,
+			Status:   status.ResourcePending,
+		}
+		if waiting := waitingOn(r, present); len(waiting) > 0 {
+			pending.Reason = "waiting on " + strings.Join(waiting, ", ")
+		}
+		out = append(out, pending)
+	}
+	return out
+}
+
+// waitingOn returns the dependencies of a resource which have not finished
+// yet.
+func waitingOn(r template.Resource, statuses map[string]types.ResourceStatus) []string {
+	var out []string
+	for _, d := range r.DependsOn {
+		if s, ok := statuses[d]; !ok || status.Resource(s) != status.Success {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// HookResults lists the hook invocations made against the given stack.
+func (f *fetcher) HookResults(ctx context.Context, stackID string) ([]HookResult, error) {
+	params := &cloudformation.ListHookResultsInput{
+		TargetId:   aws.String(stackID),
+		TargetType: types.ListHookResultsTargetTypeStack,
+	}
+
+	out := []HookResult{}
+	for {
+		res, err := f.client.ListHookResults(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("listing hook results: %w", err)
+		}
+		for _, h := range res.HookResults {
+			out = append(out, HookResult{
+				Type:            deref(h.TypeName),
+				Version:         deref(h.TypeVersionId),
+				Status:          h.Status,
+				Reason:          deref(h.HookStatusReason),
+				InvocationPoint: h.InvocationPoint,
+				FailureMode:     h.FailureMode,
+			})
+		}
+		if res.NextToken == nil {
+			break
+		}
+		params.NextToken = res.NextToken
+	}
+	return out, nil
+}
+
+// Snapshot fetches everything needed to render the current state of the
+// stack in one go.
+func (f *fetcher) Snapshot(ctx context.Context) (*Snapshot, error) {
+	stack, err := f.Stack(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := f.currentTemplate(ctx, stack)
+	resources, err := f.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, pendingResources(tmpl, stack, resources)...)
+	events, err := f.Events(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := latestHooks(events)
+	for i := range resources {
+		resources[i].Hooks = hooks[resources[i].Resource]
+	}
+
+	snap := &Snapshot{
+		Time:      time.Now(),
+		Stack:     *stack,
+		Resources: resources,
+		Events:    events,
+		Template:  tmpl,
+	}
+
+	// only ask for hook results when hooks are known to be active on the
+	// stack, to avoid an extra API call per poll
+	if len(hooks) > 0 {
+		snap.HookResults, err = f.HookResults(ctx, stack.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return snap, nil
+}
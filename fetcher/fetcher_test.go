@@ -2,19 +2,52 @@ package fetcher
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
 	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/status"
 )
 
 type handlerFunc func(ctx context.Context, params *cloudformation.DescribeStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourcesOutput, error)
 
+type eventsHandlerFunc func(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error)
+
+type hookResultsHandlerFunc func(ctx context.Context, params *cloudformation.ListHookResultsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListHookResultsOutput, error)
+
+type stacksHandlerFunc func(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+
+type templateHandlerFunc func(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error)
+
+type cancelHandlerFunc func(ctx context.Context, params *cloudformation.CancelUpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CancelUpdateStackOutput, error)
+
+type continueRollbackHandlerFunc func(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error)
+
 type mockClient struct {
 	fns []handlerFunc
 	i   int
+
+	eventFns []eventsHandlerFunc
+	eventI   int
+
+	hookFns []hookResultsHandlerFunc
+	hookI   int
+
+	stackFns []stacksHandlerFunc
+	stackI   int
+
+	templateFns []templateHandlerFunc
+	templateI   int
+
+	cancelFns []cancelHandlerFunc
+	cancelI   int
+
+	continueFns []continueRollbackHandlerFunc
+	continueI   int
 }
 
 func (m *mockClient) DescribeStackResources(ctx context.Context, params *cloudformation.DescribeStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourcesOutput, error) {
@@ -29,10 +62,111 @@ func (m *mockClient) DescribeStackResources(ctx context.Context, params *cloudfo
 	return res, err
 }
 
+func (m *mockClient) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	if m.stackI >= len(m.stackFns) {
+		panic("too few stack functions defined")
+	}
+	res, err := m.stackFns[m.stackI](ctx, params, optFns...)
+	m.stackI++
+	return res, err
+}
+
+func (m *mockClient) DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	if m.eventI >= len(m.eventFns) {
+		panic("too few event functions defined")
+	}
+	res, err := m.eventFns[m.eventI](ctx, params, optFns...)
+	m.eventI++
+	return res, err
+}
+
+func (m *mockClient) ListHookResults(ctx context.Context, params *cloudformation.ListHookResultsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListHookResultsOutput, error) {
+	if m.hookI >= len(m.hookFns) {
+		panic("too few hook result functions defined")
+	}
+	res, err := m.hookFns[m.hookI](ctx, params, optFns...)
+	m.hookI++
+	return res, err
+}
+
+func (m *mockClient) GetTemplate(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+	if m.templateI >= len(m.templateFns) {
+		panic("too few template functions defined")
+	}
+	res, err := m.templateFns[m.templateI](ctx, params, optFns...)
+	m.templateI++
+	return res, err
+}
+
+func (m *mockClient) CancelUpdateStack(ctx context.Context, params *cloudformation.CancelUpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CancelUpdateStackOutput, error) {
+	if m.cancelI >= len(m.cancelFns) {
+		panic("too few cancel functions defined")
+	}
+	res, err := m.cancelFns[m.cancelI](ctx, params, optFns...)
+	m.cancelI++
+	return res, err
+}
+
+func (m *mockClient) ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+	if m.continueI >= len(m.continueFns) {
+		panic("too few continue rollback functions defined")
+	}
+	res, err := m.continueFns[m.continueI](ctx, params, optFns...)
+	m.continueI++
+	return res, err
+}
+
 func (m *mockClient) assertNumFunctionsCalled(t *testing.T) {
 	if m.i != len(m.fns) {
 		t.Fatalf("too few function calls compared to setup, found %d expected %d", m.i, len(m.fns))
 	}
+	if m.eventI != len(m.eventFns) {
+		t.Fatalf("too few event function calls compared to setup, found %d expected %d", m.eventI, len(m.eventFns))
+	}
+	if m.hookI != len(m.hookFns) {
+		t.Fatalf("too few hook result function calls compared to setup, found %d expected %d", m.hookI, len(m.hookFns))
+	}
+	if m.stackI != len(m.stackFns) {
+		t.Fatalf("too few stack function calls compared to setup, found %d expected %d", m.stackI, len(m.stackFns))
+	}
+	if m.templateI != len(m.templateFns) {
+		t.Fatalf("too few template function calls compared to setup, found %d expected %d", m.templateI, len(m.templateFns))
+	}
+	if m.cancelI != len(m.cancelFns) {
+		t.Fatalf("too few cancel function calls compared to setup, found %d expected %d", m.cancelI, len(m.cancelFns))
+	}
+	if m.continueI != len(m.continueFns) {
+		t.Fatalf("too few continue rollback function calls compared to setup, found %d expected %d", m.continueI, len(m.continueFns))
+	}
+}
+
+func describeStack(status types.StackStatus) stacksHandlerFunc {
+	return func(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+		stacks := []types.Stack{
+			{
+				StackName:   params.StackName,
+				StackId:     aws.String("stack-id"),
+				StackStatus: status,
+			},
+		}
+		return &cloudformation.DescribeStacksOutput{Stacks: stacks}, nil
+	}
+}
+
+func getTemplate(body string) templateHandlerFunc {
+	return func(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+		return &cloudformation.GetTemplateOutput{TemplateBody: aws.String(body)}, nil
+	}
+}
+
+func describeResources(resources ...types.StackResource) handlerFunc {
+	return func(ctx context.Context, params *cloudformation.DescribeStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourcesOutput, error) {
+		return &cloudformation.DescribeStackResourcesOutput{StackResources: resources}, nil
+	}
+}
+
+func noEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	return &cloudformation.DescribeStackEventsOutput{}, nil
 }
 
 func TestFetchStatusesNoResources(t *testing.T) {
@@ -165,3 +299,314 @@ func TestFailure(t *testing.T) {
 	})
 
 }
+
+func TestSnapshotWithoutHooks(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.stackFns = append(client.stackFns, describeStack(types.StackStatusCreateComplete))
+	client.templateFns = append(client.templateFns, getTemplate(`{"Resources": {"Resource": {"Type": "AWS::SNS::Topic"}}}`))
+	client.fns = append(client.fns, func(ctx context.Context, params *cloudformation.DescribeStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourcesOutput, error) {
+		resources := []types.StackResource{
+			{
+				LogicalResourceId: aws.String("Resource"),
+				ResourceStatus:    types.ResourceStatusCreateComplete,
+			},
+		}
+		out := &cloudformation.DescribeStackResourcesOutput{
+			StackResources: resources,
+		}
+		return out, nil
+	})
+	client.eventFns = append(client.eventFns, func(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+		events := []types.StackEvent{
+			{
+				StackId:           aws.String("stack-id"),
+				LogicalResourceId: aws.String("Resource"),
+				ResourceStatus:    types.ResourceStatusCreateComplete,
+			},
+		}
+		return &cloudformation.DescribeStackEventsOutput{StackEvents: events}, nil
+	})
+	defer client.assertNumFunctionsCalled(t)
+
+	fetcher := fetcher{stackName: "stack", client: client}
+	snap, err := fetcher.Snapshot(context.Background())
+	is.NoErr(err)
+	is.Equal(snap.Stack.Name, "stack")
+	is.Equal(snap.Stack.Status, types.StackStatusCreateComplete)
+	is.Equal(len(snap.Events), 1)
+	is.Equal(snap.Resources[0].Hooks, nil)
+	is.Equal(snap.HookResults, nil)
+}
+
+func TestSnapshotWithHooks(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.stackFns = append(client.stackFns, describeStack(types.StackStatusCreateFailed))
+	client.templateFns = append(client.templateFns, getTemplate(`{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket"}, "Queue": {"Type": "AWS::SQS::Queue"}}}`))
+	client.fns = append(client.fns, func(ctx context.Context, params *cloudformation.DescribeStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourcesOutput, error) {
+		resources := []types.StackResource{
+			{
+				LogicalResourceId:    aws.String("Bucket"),
+				ResourceStatus:       types.ResourceStatusCreateFailed,
+				ResourceStatusReason: aws.String("hook failed"),
+			},
+			{
+				LogicalResourceId: aws.String("Queue"),
+				ResourceStatus:    types.ResourceStatusCreateComplete,
+			},
+		}
+		out := &cloudformation.DescribeStackResourcesOutput{
+			StackResources: resources,
+		}
+		return out, nil
+	})
+	client.eventFns = append(client.eventFns, func(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+		events := []types.StackEvent{
+			{
+				StackId:             aws.String("stack-id"),
+				LogicalResourceId:   aws.String("Bucket"),
+				HookType:            aws.String("Org::S3::Encryption"),
+				HookStatus:          types.HookStatusHookCompleteFailed,
+				HookStatusReason:    aws.String("bucket is not encrypted"),
+				HookInvocationPoint: types.HookInvocationPointPreProvision,
+				HookFailureMode:     types.HookFailureModeFail,
+			},
+			{
+				StackId:             aws.String("stack-id"),
+				LogicalResourceId:   aws.String("Bucket"),
+				HookType:            aws.String("Org::S3::Encryption"),
+				HookStatus:          types.HookStatusHookInProgress,
+				HookInvocationPoint: types.HookInvocationPointPreProvision,
+				HookFailureMode:     types.HookFailureModeFail,
+			},
+		}
+		return &cloudformation.DescribeStackEventsOutput{StackEvents: events}, nil
+	})
+	client.hookFns = append(client.hookFns, func(ctx context.Context, params *cloudformation.ListHookResultsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListHookResultsOutput, error) {
+		is.Equal(*params.TargetId, "stack-id")
+		results := []types.HookResultSummary{
+			{
+				TypeName:         aws.String("Org::S3::Encryption"),
+				Status:           types.HookStatusHookCompleteFailed,
+				HookStatusReason: aws.String("bucket is not encrypted"),
+				FailureMode:      types.HookFailureModeFail,
+				InvocationPoint:  types.HookInvocationPointPreProvision,
+			},
+		}
+		return &cloudformation.ListHookResultsOutput{HookResults: results}, nil
+	})
+	defer client.assertNumFunctionsCalled(t)
+
+	fetcher := fetcher{client: client}
+	snap, err := fetcher.Snapshot(context.Background())
+	is.NoErr(err)
+
+	is.Equal(len(snap.Resources[0].Hooks), 1)
+	hook := snap.Resources[0].Hooks[0]
+	is.Equal(hook.Type, "Org::S3::Encryption")
+	is.Equal(hook.Status, types.HookStatusHookCompleteFailed)
+	is.Equal(hook.Reason, "bucket is not encrypted")
+	is.True(hook.Failed())
+	is.Equal(snap.Resources[1].Hooks, nil)
+
+	is.Equal(snap.HookResults, []HookResult{
+		{
+			Type:            "Org::S3::Encryption",
+			Status:          types.HookStatusHookCompleteFailed,
+			Reason:          "bucket is not encrypted",
+			InvocationPoint: types.HookInvocationPointPreProvision,
+			FailureMode:     types.HookFailureModeFail,
+		},
+	})
+}
+
+const pendingTemplate = `
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Queue:
+    Type: AWS::SQS::Queue
+    DependsOn: Bucket
+  Topic:
+    Type: AWS::SNS::Topic
+    Properties:
+      KmsMasterKeyId: !GetAtt Queue.Arn
+  Alarm:
+    Type: AWS::CloudWatch::Alarm
+    Condition: IsProd
+`
+
+func TestSnapshotPendingResources(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.stackFns = append(client.stackFns, describeStack(types.StackStatusCreateInProgress), describeStack(types.StackStatusCreateInProgress))
+	client.templateFns = append(client.templateFns, getTemplate(pendingTemplate))
+	client.fns = append(client.fns,
+		describeResources(types.StackResource{
+			LogicalResourceId: aws.String("Bucket"),
+			ResourceStatus:    types.ResourceStatusCreateInProgress,
+		}),
+		describeResources(
+			types.StackResource{
+				LogicalResourceId: aws.String("Bucket"),
+				ResourceStatus:    types.ResourceStatusCreateComplete,
+			},
+			types.StackResource{
+				LogicalResourceId: aws.String("Queue"),
+				ResourceStatus:    types.ResourceStatusCreateInProgress,
+			},
+		),
+	)
+	client.eventFns = append(client.eventFns, noEvents, noEvents)
+	defer client.assertNumFunctionsCalled(t)
+
+	fetcher := fetcher{stackName: "stack", client: client}
+	snap, err := fetcher.Snapshot(context.Background())
+	is.NoErr(err)
+	is.Equal(len(snap.Resources), 3) // the conditional alarm is left out
+	is.Equal(snap.Resources[1], StackResource{Resource: "Queue", Type: "AWS::SQS::Queue", Status: status.ResourcePending, Reason: "waiting on Bucket"})
+	is.Equal(snap.Resources[2].Reason, "waiting on Queue")
+
+	progress := snap.Progress()
+	is.Equal(progress.Total, 3)
+	is.Equal(progress.Done(), 0)
+	is.Equal(progress.Counts[status.Pending], 2)
+
+	// the template is only fetched once per operation
+	snap, err = fetcher.Snapshot(context.Background())
+	is.NoErr(err)
+	is.Equal(len(snap.Resources), 3)
+	is.Equal(snap.Resources[1].Status, types.ResourceStatusCreateInProgress)
+	is.Equal(snap.Resources[2].Reason, "waiting on Queue")
+	is.Equal(snap.Progress().Done(), 1)
+}
+
+func TestSnapshotNoPendingResourcesWhenSettled(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.stackFns = append(client.stackFns, describeStack(types.StackStatusRollbackComplete))
+	client.templateFns = append(client.templateFns, getTemplate(pendingTemplate))
+	client.fns = append(client.fns, describeResources(types.StackResource{
+		LogicalResourceId: aws.String("Bucket"),
+		ResourceStatus:    types.ResourceStatusDeleteComplete,
+	}))
+	client.eventFns = append(client.eventFns, noEvents)
+	defer client.assertNumFunctionsCalled(t)
+
+	fetcher := fetcher{stackName: "stack", client: client}
+	snap, err := fetcher.Snapshot(context.Background())
+	is.NoErr(err)
+	is.Equal(len(snap.Resources), 1)
+	is.True(snap.Template != nil)
+}
+
+func TestSnapshotWithoutTemplate(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.stackFns = append(client.stackFns, describeStack(types.StackStatusCreateInProgress), describeStack(types.StackStatusCreateInProgress))
+	client.templateFns = append(client.templateFns, func(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+		return nil, errors.New("throttled")
+	}, getTemplate(pendingTemplate))
+	bucket := types.StackResource{
+		LogicalResourceId: aws.String("Bucket"),
+		ResourceStatus:    types.ResourceStatusCreateInProgress,
+	}
+	client.fns = append(client.fns, describeResources(bucket), describeResources(bucket))
+	client.eventFns = append(client.eventFns, noEvents, noEvents)
+	defer client.assertNumFunctionsCalled(t)
+
+	fetcher := fetcher{stackName: "stack", client: client}
+	snap, err := fetcher.Snapshot(context.Background())
+	is.NoErr(err)
+	is.Equal(snap.Template, nil)
+	is.Equal(snap.Progress().Total, 1)
+
+	// the template is fetched again on the next poll
+	snap, err = fetcher.Snapshot(context.Background())
+	is.NoErr(err)
+	is.True(snap.Template != nil)
+	is.Equal(snap.Progress().Total, 3)
+}
+
+func TestCancelUpdate(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.cancelFns = append(client.cancelFns, func(ctx context.Context, params *cloudformation.CancelUpdateStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CancelUpdateStackOutput, error) {
+		is.Equal(*params.StackName, "stack")
+		return &cloudformation.CancelUpdateStackOutput{}, nil
+	})
+	defer client.assertNumFunctionsCalled(t)
+
+	fetcher := New("stack", client)
+	is.NoErr(fetcher.CancelUpdate(context.Background()))
+}
+
+func TestContinueRollback(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.continueFns = append(client.continueFns,
+		func(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+			is.Equal(*params.StackName, "stack")
+			is.Equal(params.ResourcesToSkip, []string{"Bucket", "Queue"})
+			return &cloudformation.ContinueUpdateRollbackOutput{}, nil
+		},
+		func(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+			return nil, errors.New("stack is not in UPDATE_ROLLBACK_FAILED")
+		},
+	)
+	defer client.assertNumFunctionsCalled(t)
+
+	fetcher := New("stack", client)
+	is.NoErr(fetcher.ContinueRollback(context.Background(), []string{"Bucket", "Queue"}))
+
+	err := fetcher.ContinueRollback(context.Background(), nil)
+	is.True(err != nil)
+}
+
+func TestSnapshotStackNotFound(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.stackFns = append(client.stackFns, func(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "ValidationError", Message: "Stack with id stack does not exist"}
+	})
+	defer client.assertNumFunctionsCalled(t)
+
+	fetcher := New("stack", client)
+	_, err := fetcher.Snapshot(context.Background())
+	is.True(errors.Is(err, ErrStackNotFound))
+}
+
+func TestHistory(t *testing.T) {
+	is := is.New(t)
+
+	client := &mockClient{}
+	client.eventFns = append(client.eventFns, func(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+		is.True(params.NextToken == nil)
+		return &cloudformation.DescribeStackEventsOutput{
+			StackEvents: []types.StackEvent{{EventId: aws.String("2"), LogicalResourceId: aws.String("Bucket")}},
+			NextToken:   aws.String("page-2"),
+		}, nil
+	}, func(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+		is.Equal(*params.NextToken, "page-2")
+		return &cloudformation.DescribeStackEventsOutput{
+			StackEvents: []types.StackEvent{{EventId: aws.String("1"), LogicalResourceId: aws.String("Queue")}},
+		}, nil
+	})
+
+	f := New("stack", client)
+	events, err := f.History(context.Background())
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(events[0].ID, "2")
+	is.Equal(events[1].Resource, "Queue")
+	client.assertNumFunctionsCalled(t)
+}
@@ -0,0 +1,58 @@
+package fetcher
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// HookInvocation is a hook run against a single resource, as reported in the
+// stack events.
+type HookInvocation struct {
+	Type            string
+	Resource        string
+	Status          types.HookStatus
+	Reason          string
+	InvocationPoint types.HookInvocationPoint
+	FailureMode     types.HookFailureMode
+	Timestamp       time.Time
+}
+
+// Failed returns whether the hook rejected its target.
+func (h HookInvocation) Failed() bool {
+	return h.Status == types.HookStatusHookCompleteFailed || h.Status == types.HookStatusHookFailed
+}
+
+// HookResult is a summary of a hook invocation from ListHookResults.
+type HookResult struct {
+	Type            string
+	Version         string
+	Status          types.HookStatus
+	Reason          string
+	InvocationPoint types.HookInvocationPoint
+	FailureMode     types.HookFailureMode
+}
+
+// latestHooks returns the most recent invocation of each hook for each
+// resource, keyed by logical resource id. Events must be newest first, as
+// returned by DescribeStackEvents.
+func latestHooks(events []StackEvent) map[string][]HookInvocation {
+	type key struct {
+		resource, hook string
+		point          types.HookInvocationPoint
+	}
+	seen := map[key]bool{}
+	out := map[string][]HookInvocation{}
+	for _, e := range events {
+		if e.Hook == nil {
+			continue
+		}
+		k := key{e.Hook.Resource, e.Hook.Type, e.Hook.InvocationPoint}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out[e.Hook.Resource] = append(out[e.Hook.Resource], *e.Hook)
+	}
+	return out
+}
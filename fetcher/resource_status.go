@@ -1,9 +1,22 @@
 package fetcher
 
-import "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
 
 type StackResource struct {
-	Resource string
-	Status   types.ResourceStatus
-	Reason   string
+	Resource   string
+	Type       string
+	PhysicalID string
+	Status     types.ResourceStatus
+	Reason     string
+
+	// Timestamp is when the status last changed.
+	Timestamp time.Time
+
+	// Hooks holds the latest invocation of each hook run against the
+	// resource, if any.
+	Hooks []HookInvocation
 }
@@ -0,0 +1,48 @@
+package fetcher
+
+import (
+	"time"
+
+	"github.com/simonrw/cflivestatus/status"
+	"github.com/simonrw/cflivestatus/template"
+)
+
+// Snapshot is the state of a stack at a single point in time.
+type Snapshot struct {
+	Time        time.Time
+	Stack       Stack
+	Resources   []StackResource
+	Events      []StackEvent
+	HookResults []HookResult
+	// Template is the stack's current template, or nil if it could not be
+	// fetched.
+	Template *template.Template
+}
+
+// Progress counts the resources of a stack by category.
+type Progress struct {
+	Total  int
+	Counts map[status.Category]int
+}
+
+// Done returns how many resources have stopped changing.
+func (p Progress) Done() int {
+	n := 0
+	for c, count := range p.Counts {
+		if c.Settled() {
+			n += count
+		}
+	}
+	return n
+}
+
+// Progress summarises how far through the current operation the stack is.
+// Resources which have not started yet are only known about if the template
+// was fetched.
+func (s *Snapshot) Progress() Progress {
+	p := Progress{Total: len(s.Resources), Counts: map[status.Category]int{}}
+	for _, r := range s.Resources {
+		p.Counts[status.Resource(r.Status)]++
+	}
+	return p
+}
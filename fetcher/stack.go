@@ -0,0 +1,48 @@
+package fetcher
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// Stack is the stack level status, as opposed to that of its resources.
+type Stack struct {
+	Name        string
+	ID          string
+	Status      types.StackStatus
+	Reason      string
+	Created     time.Time
+	LastUpdated time.Time
+	Outputs     []Output
+}
+
+// Output is a value exported by the stack.
+type Output struct {
+	Key         string
+	Value       string
+	Description string
+}
+
+func newStack(s types.Stack) Stack {
+	out := Stack{
+		Name:   deref(s.StackName),
+		ID:     deref(s.StackId),
+		Status: s.StackStatus,
+		Reason: deref(s.StackStatusReason),
+	}
+	for _, o := range s.Outputs {
+		out.Outputs = append(out.Outputs, Output{
+			Key:         deref(o.OutputKey),
+			Value:       deref(o.OutputValue),
+			Description: deref(o.Description),
+		})
+	}
+	if s.CreationTime != nil {
+		out.Created = *s.CreationTime
+	}
+	if s.LastUpdatedTime != nil {
+		out.LastUpdated = *s.LastUpdatedTime
+	}
+	return out
+}
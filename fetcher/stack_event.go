@@ -0,0 +1,72 @@
+package fetcher
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// StackEvent is a single entry from the stack event log.
+type StackEvent struct {
+	ID                 string
+	StackID            string
+	Resource           string
+	PhysicalID         string
+	ResourceType       string
+	Status             types.ResourceStatus
+	Reason             string
+	Timestamp          time.Time
+	ClientRequestToken string
+
+	// Hook is set when the event records a hook invocation rather than a
+	// resource status change.
+	Hook *HookInvocation
+}
+
+// StackLevel returns whether the event is about the stack itself, rather
+// than one of its resources.
+func (e StackEvent) StackLevel() bool {
+	return e.PhysicalID != "" && e.PhysicalID == e.StackID
+}
+
+// StartsOperation returns whether the event is the stack starting to be
+// created, updated, deleted or imported.
+func (e StackEvent) StartsOperation() bool {
+	return e.StackLevel() && status.StartsOperation(types.StackStatus(e.Status))
+}
+
+func newStackEvent(e types.StackEvent) StackEvent {
+	out := StackEvent{
+		ID:                 deref(e.EventId),
+		StackID:            deref(e.StackId),
+		Resource:           deref(e.LogicalResourceId),
+		PhysicalID:         deref(e.PhysicalResourceId),
+		ResourceType:       deref(e.ResourceType),
+		Status:             e.ResourceStatus,
+		Reason:             deref(e.ResourceStatusReason),
+		ClientRequestToken: deref(e.ClientRequestToken),
+	}
+	if e.Timestamp != nil {
+		out.Timestamp = *e.Timestamp
+	}
+	if e.HookType != nil {
+		out.Hook = &HookInvocation{
+			Type:            *e.HookType,
+			Resource:        out.Resource,
+			Status:          e.HookStatus,
+			Reason:          deref(e.HookStatusReason),
+			InvocationPoint: e.HookInvocationPoint,
+			FailureMode:     e.HookFailureMode,
+			Timestamp:       out.Timestamp,
+		}
+	}
+	return out
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
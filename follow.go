@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/plain"
+	"github.com/simonrw/cflivestatus/report"
+	"github.com/simonrw/cflivestatus/runner"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// errOperationFailed is returned in plain mode when the operation did not
+// succeed, so that the exit status shows it.
+var errOperationFailed = errors.New("the stack operation did not succeed")
+
+// finalPollTimeout is how long to wait, once a wrapped command exits, for a
+// last look at the stack.
+const finalPollTimeout = 10 * time.Second
+
+// display shows the stack until the operation finishes or the user quits,
+// in the terminal UI or, in plain mode, as lines of text.
+func (a *app) display(ctx context.Context, proc *runner.Process) error {
+	if a.plain {
+		return a.follow(ctx, proc)
+	}
+	return a.monitor(ctx, proc)
+}
+
+// follow prints the stack's events as they happen until the operation
+// finishes or, if proc is not nil, until proc exits. Under GitHub Actions,
+// failures annotate the run and a summary is added to the job.
+func (a *app) follow(ctx context.Context, proc *runner.Process) error {
+	dispatcher, err := newDispatcher(a.opts.Webhooks, a.opts.WebhookTemplate, a.opts.NotifyOn, a.opts.StuckAfter)
+	if err != nil {
+		return fmt.Errorf("invalid notification configuration: %w", err)
+	}
+	github := os.Getenv("GITHUB_ACTIONS") == "true"
+	printer := plain.New(os.Stdout, a.stackEvents, github)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	snaps := make(chan *fetcher.Snapshot)
+	stop := a.startPolling(ctx, dispatcher, snaps)
+	defer stop()
+
+	var exited <-chan struct{}
+	if proc != nil {
+		exited = proc.Done()
+	}
+	var deadline <-chan time.Time
+	// when a change set has just been executed, the stack may not show the
+	// operation yet
+	started := !a.expectOperation
+	var last *fetcher.Snapshot
+	for {
+		select {
+		case snap := <-snaps:
+			last = snap
+			printer.Observe(ctx, snap)
+			if status.Stack(snap.Stack.Status).Active() {
+				started = true
+			}
+			switch {
+			case deadline != nil:
+				// the last look after the command exited
+				return a.summarise(last, github, false)
+			case proc == nil && started && status.Finished(snap.Stack.Status):
+				return a.summarise(last, github, true)
+			}
+		case <-exited:
+			exited = nil
+			deadline = time.After(finalPollTimeout)
+		case <-deadline:
+			return a.summarise(last, github, false)
+		}
+	}
+}
+
+// summarise adds a summary of the operation to the GitHub Actions job, and
+// if check is set returns errOperationFailed unless it succeeded.
+func (a *app) summarise(snap *fetcher.Snapshot, github, check bool) error {
+	if snap == nil {
+		return nil
+	}
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); github && path != "" {
+		if err := appendSummary(path, snap); err != nil {
+			return err
+		}
+	}
+	if check && !status.Succeeding(snap.Stack.Status) {
+		return errOperationFailed
+	}
+	return nil
+}
+
+func appendSummary(path string, snap *fetcher.Snapshot) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening step summary: %w", err)
+	}
+	defer f.Close()
+	return report.WriteMarkdown(f, report.New(snap))
+}
+
+// isTerminal returns whether f is a terminal, on which the UI can be drawn.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/simonrw/cflivestatus/changes"
+	"github.com/simonrw/cflivestatus/failure"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/operation"
+	"github.com/simonrw/cflivestatus/status"
+	"github.com/simonrw/cflivestatus/theme"
+)
+
+// eventHistory fetches the whole event log of the stack.
+type eventHistory interface {
+	History(ctx context.Context) ([]fetcher.StackEvent, error)
+}
+
+// historyBrowser lists past operations on the stack, and replays any one
+// of them.
+type historyBrowser struct {
+	loading bool
+	err     error
+	ops     []operation.Operation
+	cursor  int
+
+	// opened is set while the operation under the cursor is replayed.
+	opened bool
+	// replay is how many of the opened operation's events have happened.
+	replay   int
+	timeline bool
+}
+
+// loadHistory fetches the event log in the background, sending the
+// operations found in it on results.
+func (a *app) loadHistory(ctx context.Context, results chan<- historyResult) {
+	go func() {
+		events, err := a.history.History(ctx)
+		results <- historyResult{ops: operation.Split(events), err: err}
+	}()
+}
+
+type historyResult struct {
+	ops []operation.Operation
+	err error
+}
+
+// ToggleHistory shows or hides the history browser, returning whether it
+// is now shown and so needs loading.
+func (s *Screen) ToggleHistory() bool {
+	if s.view == historyView {
+		s.view = resourcesView
+		return false
+	}
+	s.view = historyView
+	s.history = historyBrowser{loading: true}
+	return true
+}
+
+// SetHistory shows the operations loaded from the event log.
+func (s *Screen) SetHistory(r historyResult) {
+	s.history.loading = false
+	s.history.ops, s.history.err = r.ops, r.err
+}
+
+// StepReplay moves the replay of the opened operation on or back by delta
+// events.
+func (s *Screen) StepReplay(delta int) {
+	h := &s.history
+	if s.view != historyView || !h.opened {
+		return
+	}
+	h.replay = min(max(h.replay+delta, 1), len(h.ops[h.cursor].Events))
+}
+
+// ToggleTimeline switches the opened operation between its resources and
+// its timeline of events.
+func (s *Screen) ToggleTimeline() {
+	if s.view == historyView && s.history.opened {
+		s.history.timeline = !s.history.timeline
+	}
+}
+
+func (h *historyBrowser) move(delta int) {
+	if h.opened || len(h.ops) == 0 {
+		return
+	}
+	h.cursor = min(max(h.cursor+delta, 0), len(h.ops)-1)
+}
+
+// toggleOpen replays the operation under the cursor from its end, or
+// returns to the list.
+func (h *historyBrowser) toggleOpen() {
+	if h.opened {
+		h.opened = false
+		return
+	}
+	if len(h.ops) == 0 {
+		return
+	}
+	h.opened = true
+	h.timeline = false
+	h.replay = len(h.ops[h.cursor].Events)
+}
+
+func (s *Screen) renderHistory(i int) {
+	style := s.theme.Style(theme.Default)
+	h := s.history
+	switch {
+	case h.loading:
+		s.write(i, style, "Deployments%s", hints(s.keys.hint("return to the live view", actionToggleHistory)))
+		s.write(i+1, style, "loading the stack's event history...")
+		return
+	case h.err != nil:
+		s.write(i, style, "Deployments%s", hints(s.keys.hint("return to the live view", actionToggleHistory)))
+		s.write(i+1, s.theme.Style(theme.Failed), "could not load the event history: %v", h.err)
+		return
+	case h.opened:
+		s.renderReplay(i, h.ops[h.cursor])
+		return
+	}
+
+	s.write(i, style, "Deployments%s", hints(s.keys.hint("replay", actionDetails), s.keys.hint("return to the live view", actionToggleHistory)))
+	i++
+	if len(h.ops) == 0 {
+		s.write(i, style, "no operations found")
+		return
+	}
+
+	// keep the cursor on screen
+	_, height := (*s.s).Size()
+	rows := max(height-i, 1)
+	first := max(h.cursor-rows+1, 0)
+	now := time.Now()
+	for n := first; n < len(h.ops) && i < height; n++ {
+		op := h.ops[n]
+		category := themeCategories[op.Outcome()]
+		rowStyle := s.theme.Style(category)
+		if n == h.cursor {
+			rowStyle = rowStyle.Reverse(true)
+		}
+		line := fmt.Sprintf("%s%s  %-7s %8s  %s", s.glyph(category), op.Start.Local().Format("2006-01-02 15:04:05"), kindOrUnknown(op), formatAge(op.Duration(now)), op.Status)
+		if !op.Finished() {
+			line += " (in progress)"
+		} else if op.Reason != "" && op.Outcome() != status.Success {
+			line += fmt.Sprintf(" (%s)", op.Reason)
+		}
+		s.write(i, rowStyle, "%s", line)
+		i++
+	}
+}
+
+func kindOrUnknown(op operation.Operation) string {
+	if k := op.Kind(); k != "" {
+		return k
+	}
+	return "?"
+}
+
+// renderReplay draws an operation as it was once the first events had
+// happened, either as the resource table or as a timeline of events.
+func (s *Screen) renderReplay(i int, op operation.Operation) {
+	style := s.theme.Style(theme.Default)
+	h := s.history
+	snap := op.SnapshotAt(h.replay)
+
+	s.write(i, style, "%s started %s, took %s: %s%s", kindOrUnknown(op), op.Start.Local().Format("2006-01-02 15:04:05"), formatAge(op.Duration(time.Now())), op.Status,
+		hints(s.keys.hint("return to the list", actionDetails), s.keys.hint("return to the live view", actionToggleHistory)))
+	i++
+	s.write(i, style.Bold(true), "Event %d of %d at %s, +%s%s", h.replay, len(op.Events), snap.Time.Local().Format("15:04:05"), formatAge(snap.Time.Sub(op.Start)),
+		hints(s.keys.hint("replay", actionLeft, actionRight), s.keys.hint("switch to the timeline", actionToggleTimeline)))
+	i += 2
+
+	if !h.timeline {
+		i = s.renderRootCauses(i, failure.Analyse(snap.Events))
+		s.renderResources(i, snap.Resources, changes.NewTracker())
+		return
+	}
+
+	// the most recent events which fit, ending with the current one
+	_, height := (*s.s).Size()
+	events := op.Events[:h.replay]
+	if rows := max(height-i, 1); len(events) > rows {
+		events = events[len(events)-rows:]
+	}
+	for n, e := range events {
+		category := resourceCategory(e.Status)
+		eventStyle := s.theme.Style(category)
+		if n == len(events)-1 {
+			eventStyle = eventStyle.Bold(true)
+		}
+		line := fmt.Sprintf("+%-7s %s %s%s %s", formatAge(e.Timestamp.Sub(op.Start)), e.Timestamp.Local().Format("15:04:05"), s.glyph(category), s.name(e.Resource), e.Status)
+		if e.Hook != nil {
+			line = fmt.Sprintf("+%-7s %s %s hook %s %s", formatAge(e.Timestamp.Sub(op.Start)), e.Timestamp.Local().Format("15:04:05"), s.name(e.Resource), e.Hook.Type, e.Hook.Status)
+		}
+		if e.Reason != "" {
+			line += fmt.Sprintf(" (%s)", e.Reason)
+		}
+		s.write(i, eventStyle, "%s", line)
+		i++
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// action is something the user can ask the UI to do.
+type action int
+
+const (
+	actionNone action = iota
+	actionQuit
+	actionRedraw
+	actionToggleHooks
+	actionToggleGraph
+	actionCancelUpdate
+	actionContinueRollback
+	actionUp
+	actionDown
+	actionDetails
+	actionToggleLogs
+	actionToggleHistory
+	actionLeft
+	actionRight
+	actionToggleTimeline
+	actionPause
+	actionRefresh
+	actionLongerInterval
+	actionShorterInterval
+	actionToggleHelp
+)
+
+// actionNames are the names used to rebind actions with --key.
+var actionNames = map[string]action{
+	"quit":   actionQuit,
+	"redraw": actionRedraw,
+	"hooks":  actionToggleHooks,
+	"graph":  actionToggleGraph,
+
+	"up":      actionUp,
+	"down":    actionDown,
+	"details": actionDetails,
+	"logs":    actionToggleLogs,
+
+	"history":  actionToggleHistory,
+	"back":     actionLeft,
+	"forward":  actionRight,
+	"timeline": actionToggleTimeline,
+
+	"pause":            actionPause,
+	"refresh":          actionRefresh,
+	"longer-interval":  actionLongerInterval,
+	"shorter-interval": actionShorterInterval,
+	"help":             actionToggleHelp,
+
+	"cancel-update":     actionCancelUpdate,
+	"continue-rollback": actionContinueRollback,
+}
+
+// binding is a single key, either a special key or a printable rune.
+type binding struct {
+	key tcell.Key
+	r   rune
+}
+
+var defaultBindings = map[action][]binding{
+	actionQuit:        {{key: tcell.KeyEscape}},
+	actionRedraw:      {{key: tcell.KeyCtrlL}},
+	actionToggleHooks: {{key: tcell.KeyRune, r: 'h'}},
+	actionToggleGraph: {{key: tcell.KeyRune, r: 'g'}},
+	actionUp:          {{key: tcell.KeyUp}, {key: tcell.KeyRune, r: 'k'}},
+	actionDown:        {{key: tcell.KeyDown}, {key: tcell.KeyRune, r: 'j'}},
+	actionDetails:     {{key: tcell.KeyEnter}},
+	actionToggleLogs:  {{key: tcell.KeyRune, r: 'l'}},
+	// the history browser
+	actionToggleHistory:  {{key: tcell.KeyRune, r: 'd'}},
+	actionLeft:           {{key: tcell.KeyLeft}},
+	actionRight:          {{key: tcell.KeyRight}},
+	actionToggleTimeline: {{key: tcell.KeyRune, r: 't'}},
+	// polling
+	actionPause:           {{key: tcell.KeyRune, r: 'p'}},
+	actionRefresh:         {{key: tcell.KeyRune, r: 'r'}},
+	actionLongerInterval:  {{key: tcell.KeyRune, r: '+'}},
+	actionShorterInterval: {{key: tcell.KeyRune, r: '-'}},
+	actionToggleHelp:      {{key: tcell.KeyRune, r: '?'}},
+	// actions which change the stack are on capitals, so they are not hit
+	// by accident
+	actionCancelUpdate:     {{key: tcell.KeyRune, r: 'C'}},
+	actionContinueRollback: {{key: tcell.KeyRune, r: 'R'}},
+}
+
+// actionHelp describes each action in the help overlay, in the order they
+// are listed.
+var actionHelp = []struct {
+	action action
+	text   string
+}{
+	{actionUp, "move the cursor up"},
+	{actionDown, "move the cursor down"},
+	{actionDetails, "show the selected resource or operation"},
+	{actionToggleHooks, "show hook invocations"},
+	{actionToggleGraph, "show the dependency graph"},
+	{actionToggleLogs, "show the log pane"},
+	{actionToggleHistory, "browse past operations"},
+	{actionLeft, "step a replay back"},
+	{actionRight, "step a replay forward"},
+	{actionToggleTimeline, "switch a replay to its timeline"},
+	{actionPause, "pause or resume polling"},
+	{actionRefresh, "poll now"},
+	{actionLongerInterval, "poll less often"},
+	{actionShorterInterval, "poll more often"},
+	{actionCancelUpdate, "cancel the update in progress"},
+	{actionContinueRollback, "continue a failed rollback"},
+	{actionRedraw, "redraw the screen"},
+	{actionToggleHelp, "show or hide this help"},
+	{actionQuit, "quit"},
+}
+
+// keymap translates key presses into actions.
+type keymap map[binding]action
+
+// newKeymap builds the default bindings, replacing those for any action
+// given in overrides, which maps action names to key names. A key given to
+// one action is taken away from any other action it is bound to by default,
+// while giving the same key to two actions is an error.
+func newKeymap(overrides map[string]string) (keymap, error) {
+	bindings := map[action][]binding{}
+	for a, b := range defaultBindings {
+		bindings[a] = b
+	}
+	claimed := map[binding]action{}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a, ok := actionNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown action %q", name)
+		}
+		b, err := parseBinding(overrides[name])
+		if err != nil {
+			return nil, err
+		}
+		if b == ctrlC {
+			return nil, fmt.Errorf("key %s always quits, so cannot be bound to %s", b, name)
+		}
+		if other, ok := claimed[b]; ok && other != a {
+			return nil, fmt.Errorf("key %s is bound to both %s and %s", b, actionName(other), name)
+		}
+		claimed[b] = a
+		bindings[a] = []binding{b}
+	}
+
+	km := keymap{}
+	for a, bs := range bindings {
+		for _, b := range bs {
+			if owner, ok := claimed[b]; ok && owner != a {
+				continue
+			}
+			km[b] = a
+		}
+	}
+	// ctrl-c always quits, so that a bad configuration cannot trap the user
+	km[ctrlC] = actionQuit
+	return km, nil
+}
+
+var ctrlC = binding{key: tcell.KeyCtrlC}
+
+// actionName returns the name an action is rebound by.
+func actionName(a action) string {
+	for name, b := range actionNames {
+		if b == a {
+			return name
+		}
+	}
+	return ""
+}
+
+// parseBinding understands single characters, and the names tcell uses for
+// special keys such as "Esc", "F1" or "Ctrl-L".
+func parseBinding(name string) (binding, error) {
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return binding{key: tcell.KeyRune, r: r}, nil
+	}
+	for k, n := range tcell.KeyNames {
+		if strings.EqualFold(n, name) {
+			return binding{key: k}, nil
+		}
+	}
+	return binding{}, fmt.Errorf("unknown key %q", name)
+}
+
+// keys returns the names of the keys bound to an action, in order.
+func (km keymap) keys(a action) []string {
+	var out []string
+	for b, bound := range km {
+		if bound == a {
+			out = append(out, b.String())
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// hint says which keys do something, e.g. "h to return to resources", or
+// returns nothing if none of the actions have a key.
+func (km keymap) hint(text string, actions ...action) string {
+	var keys []string
+	for _, a := range actions {
+		if k := km.keys(a); len(k) > 0 {
+			keys = append(keys, strings.Join(k, "/"))
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	return strings.Join(keys, ", ") + " to " + text
+}
+
+// hints puts the hints which are not empty in brackets, to follow a title.
+func hints(hs ...string) string {
+	var out []string
+	for _, h := range hs {
+		if h != "" {
+			out = append(out, h)
+		}
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(out, ", ") + ")"
+}
+
+// help lists the keys bound to each action alongside what it does.
+func (km keymap) help() []string {
+	width := 0
+	for _, h := range actionHelp {
+		width = max(width, len(strings.Join(km.keys(h.action), ", ")))
+	}
+
+	var lines []string
+	for _, h := range actionHelp {
+		keys := km.keys(h.action)
+		if len(keys) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width, strings.Join(keys, ", "), h.text))
+	}
+	return lines
+}
+
+func (b binding) String() string {
+	if b.key == tcell.KeyRune {
+		return string(b.r)
+	}
+	if name, ok := tcell.KeyNames[b.key]; ok {
+		return name
+	}
+	return fmt.Sprintf("key %d", b.key)
+}
+
+func (km keymap) lookup(ev *tcell.EventKey) action {
+	b := binding{key: ev.Key()}
+	if ev.Key() == tcell.KeyRune {
+		b.r = ev.Rune()
+	}
+	return km[b]
+}
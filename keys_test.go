@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/matryer/is"
+)
+
+func key(r rune) *tcell.EventKey {
+	return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+}
+
+func TestKeymapDefaults(t *testing.T) {
+	is := is.New(t)
+
+	km, err := newKeymap(nil)
+	is.NoErr(err)
+	is.Equal(km.lookup(key('h')), actionToggleHooks)
+	is.Equal(km.lookup(key('p')), actionPause)
+	is.Equal(km.lookup(key('r')), actionRefresh)
+	is.Equal(km.lookup(tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone)), actionQuit)
+	is.Equal(km.lookup(tcell.NewEventKey(tcell.KeyCtrlC, 0, tcell.ModCtrl)), actionQuit)
+}
+
+func TestKeymapOverrideTakesDefault(t *testing.T) {
+	is := is.New(t)
+
+	// h shows hooks by default, so hooks is left without a key rather than
+	// the two actions fighting over it
+	for i := 0; i < 20; i++ {
+		km, err := newKeymap(map[string]string{"redraw": "h"})
+		is.NoErr(err)
+		is.Equal(km.lookup(key('h')), actionRedraw)
+		is.Equal(km.lookup(tcell.NewEventKey(tcell.KeyCtrlL, 0, tcell.ModCtrl)), actionNone)
+		is.Equal(len(km.keys(actionToggleHooks)), 0)
+	}
+}
+
+func TestKeymapConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		overrides map[string]string
+	}{
+		{"same key twice", map[string]string{"hooks": "x", "redraw": "x"}},
+		{"ctrl-c", map[string]string{"hooks": "Ctrl-C"}},
+		{"unknown action", map[string]string{"nope": "x"}},
+		{"unknown key", map[string]string{"hooks": "Nope"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := newKeymap(tc.overrides)
+			is.True(err != nil)
+		})
+	}
+}
+
+func TestHints(t *testing.T) {
+	is := is.New(t)
+
+	km, err := newKeymap(map[string]string{"hooks": "H"})
+	is.NoErr(err)
+	is.Equal(km.hint("return to resources", actionToggleHooks), "H to return to resources")
+	is.Equal(km.hint("quit", actionQuit, actionToggleHooks), "Ctrl-C/Esc, H to quit")
+	is.Equal(km.hint("change resource", actionUp, actionDown), "Up/k, Down/j to change resource")
+	is.Equal(hints(km.hint("return", actionToggleHooks), "", km.hint("redraw", actionRedraw)), " (H to return, Ctrl-L to redraw)")
+	is.Equal(hints(keymap{}.hint("return", actionToggleHooks)), "")
+}
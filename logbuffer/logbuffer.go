@@ -0,0 +1,64 @@
+// Package logbuffer keeps the most recent lines of log output in memory, so
+// that they can be shown inside the terminal UI.
+package logbuffer
+
+import (
+	"strings"
+	"sync"
+)
+
+// Buffer is an io.Writer which keeps the last lines written to it.
+type Buffer struct {
+	mu    sync.Mutex
+	lines []string
+	// next is where the next line goes once the buffer is full.
+	next int
+	size int
+
+	updates chan struct{}
+}
+
+// New creates a buffer which keeps size lines.
+func New(size int) *Buffer {
+	return &Buffer{
+		lines:   make([]string, 0, size),
+		size:    size,
+		updates: make(chan struct{}, 1),
+	}
+}
+
+// Write adds each line in p, without its line ending.
+func (b *Buffer) Write(p []byte) (int, error) {
+	text := strings.TrimRight(string(p), "\r\n")
+	b.mu.Lock()
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(b.lines) < b.size {
+			b.lines = append(b.lines, line)
+			continue
+		}
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % b.size
+	}
+	b.mu.Unlock()
+
+	select {
+	case b.updates <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// Lines returns the lines held, oldest first.
+func (b *Buffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]string, 0, len(b.lines))
+	out = append(out, b.lines[b.next:]...)
+	return append(out, b.lines[:b.next]...)
+}
+
+// Updates receives whenever more lines have been written.
+func (b *Buffer) Updates() <-chan struct{} {
+	return b.updates
+}
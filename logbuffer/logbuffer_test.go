@@ -0,0 +1,43 @@
+package logbuffer
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestKeepsLastLines(t *testing.T) {
+	is := is.New(t)
+
+	b := New(3)
+	is.Equal(len(b.Lines()), 0)
+
+	_, err := b.Write([]byte("one\n"))
+	is.NoErr(err)
+	_, err = b.Write([]byte("two\r\nthree\n"))
+	is.NoErr(err)
+	is.Equal(b.Lines(), []string{"one", "two", "three"})
+
+	_, err = b.Write([]byte("four\n"))
+	is.NoErr(err)
+	_, err = b.Write([]byte("five"))
+	is.NoErr(err)
+	is.Equal(b.Lines(), []string{"three", "four", "five"})
+}
+
+func TestUpdates(t *testing.T) {
+	is := is.New(t)
+
+	b := New(10)
+	select {
+	case <-b.Updates():
+		t.Fatal("update before any write")
+	default:
+	}
+
+	// writes do not block when nobody is listening
+	_, _ = b.Write([]byte("one\n"))
+	_, _ = b.Write([]byte("two\n"))
+	<-b.Updates()
+	is.Equal(len(b.Lines()), 2)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/logbuffer"
+)
+
+// logLines is how many recent log lines the log pane keeps.
+const logLines = 500
+
+// logging routes log output away from the terminal while the UI is drawn on
+// it, keeping recent lines for the log pane and writing every line to the
+// --log-file, if given.
+type logging struct {
+	buffer   *logbuffer.Buffer
+	terminal *terminalLog
+}
+
+func setupLogging(path string) (*logging, error) {
+	l := &logging{
+		buffer:   logbuffer.New(logLines),
+		terminal: &terminalLog{console: zerolog.ConsoleWriter{Out: os.Stderr}},
+	}
+	writers := []io.Writer{
+		l.terminal,
+		zerolog.ConsoleWriter{Out: l.buffer, NoColor: true, TimeFormat: "15:04:05"},
+	}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("opening log file: %w", err)
+		}
+		writers = append(writers, zerolog.ConsoleWriter{Out: f, NoColor: true})
+	}
+	log.Logger = log.Output(zerolog.MultiLevelWriter(writers...))
+	return l, nil
+}
+
+// hide stops logging to the terminal until show is called. If a fatal
+// error is logged in the meantime, restore is called to give the terminal
+// back first, so the error can be seen.
+func (l *logging) hide(restore func()) {
+	l.terminal.mu.Lock()
+	defer l.terminal.mu.Unlock()
+	l.terminal.restore = restore
+}
+
+func (l *logging) show() {
+	l.terminal.mu.Lock()
+	defer l.terminal.mu.Unlock()
+	l.terminal.restore = nil
+}
+
+// terminalLog writes to standard error, unless the terminal is hidden.
+type terminalLog struct {
+	console zerolog.ConsoleWriter
+
+	mu sync.Mutex
+	// restore is set while the terminal UI is drawn.
+	restore func()
+}
+
+func (t *terminalLog) Write(p []byte) (int, error) {
+	return t.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (t *terminalLog) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.restore != nil {
+		if level != zerolog.FatalLevel && level != zerolog.PanicLevel {
+			return len(p), nil
+		}
+		// the program is about to exit
+		t.restore()
+		t.restore = nil
+	}
+	return t.console.Write(p)
+}
@@ -4,149 +4,104 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
-	"sort"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
-	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/aws/smithy-go"
-	"github.com/gdamore/tcell/v2"
 	"github.com/jessevdk/go-flags"
-	"github.com/mindriot101/cflivestatus/fetcher"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/deploy"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/metrics"
+	"github.com/simonrw/cflivestatus/notify"
+	"github.com/simonrw/cflivestatus/plain"
+	"github.com/simonrw/cflivestatus/settings"
+	"github.com/simonrw/cflivestatus/store"
 )
 
-var defStyle = tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
-var okStyle = tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorGreen)
-var updatingStyle = tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorBlue)
-var failedStyle = tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorRed)
+type options struct {
+	SleepTime time.Duration `short:"s" long:"sleep-time" required:"no" default:"0"`
+	Verbose   []bool        `short:"v" long:"verbose" description:"Print verbose logging output"`
 
-type Screen struct {
-	s *tcell.Screen
-}
+	Webhooks        []string      `long:"webhook" description:"Post notifications to this URL, may be repeated"`
+	WebhookTemplate string        `long:"webhook-template" description:"File containing a text/template for the webhook payload"`
+	NotifyOn        []string      `long:"notify-on" description:"Events which trigger notifications" choice:"finished" choice:"failure" choice:"rollback" choice:"stuck" default:"finished" default:"failure" default:"rollback" default:"stuck"`
+	StuckAfter      time.Duration `long:"stuck-after" description:"How long a resource may be in progress before it is highlighted and reported as stuck, or 0 to never treat it as stuck" default:"15m"`
 
-func NewScreen() (*Screen, error) {
-	s, err := tcell.NewScreen()
-	if err != nil {
-		return nil, fmt.Errorf("creating screen: %w", err)
-	}
-	if err := s.Init(); err != nil {
-		return nil, fmt.Errorf("initialising screen: %w", err)
-	}
-	s.SetStyle(defStyle)
-	s.Clear()
+	BellOn    []string `long:"bell-on" description:"Events which ring the terminal bell" choice:"none" choice:"finished" choice:"failure" choice:"rollback" choice:"stuck" default:"finished" default:"failure"`
+	DesktopOn []string `long:"desktop-notify-on" description:"Events which raise a desktop notification through the terminal" choice:"none" choice:"finished" choice:"failure" choice:"rollback" choice:"stuck" default:"finished" default:"failure"`
+	FlashOn   []string `long:"flash-on" description:"Events which flash the header" choice:"none" choice:"finished" choice:"failure" choice:"rollback" choice:"stuck" default:"finished" default:"failure" default:"rollback" default:"stuck"`
+	OSCNotify string   `long:"osc-notify" description:"Escape sequence used for desktop notifications" choice:"9" choice:"777" choice:"both" default:"9"`
 
-	return &Screen{s: &s}, nil
-}
+	Columns string            `long:"columns" description:"Comma separated columns to show: resource, type, status, physical-id, updated, elapsed, reason" default:"resource,status,elapsed,reason"`
+	Sort    string            `long:"sort" description:"Order of the resource table" choice:"name" choice:"status" choice:"type" choice:"updated" default:"name"`
+	Theme   string            `long:"theme" description:"Theme: default, high-contrast, deuteranopia, monochrome or a [theme NAME] from the config file (default: monochrome if NO_COLOR is set)"`
+	Colours map[string]string `long:"colour" description:"Style of a category, as category:style where category is one of default, pending, ok, updating, failed, rolling-back, rolled-back, skipped or stuck, e.g. failed:'white on red'"`
+	Glyphs  map[string]string `long:"glyph" description:"Glyph drawn before rows of a category, as category:glyph, with the same categories as --colour"`
+	Keys    map[string]string `long:"key" description:"Key bound to an action, as action:key where action is one of quit, redraw, hooks, graph, up, down, details, logs, history, back, forward, timeline, pause, refresh, longer-interval, shorter-interval, help, cancel-update or continue-rollback"`
 
-func (s *Screen) write(line int, style tcell.Style, format string, args ...interface{}) {
-	row := line
-	col := 0
-	text := fmt.Sprintf(format, args...)
-	runes := []rune(text)
-	x2 := col + len(runes)
-	for _, r := range runes {
-		(*s.s).SetContent(col, row, r, nil, style)
-		col++
-		if col > x2 {
-			row++
-			col = 0
-		}
-		if row > line {
-			break
-		}
-	}
-}
+	AWS awsOptions `group:"AWS Options"`
 
-func (s *Screen) Quit() {
-	(*s.s).Fini()
-	os.Exit(0)
-}
+	ConfigFile    string `long:"config" description:"Path to the configuration file (default: $XDG_CONFIG_HOME/cflivestatus/config)"`
+	ConfigProfile string `long:"config-profile" description:"Named profile from the configuration file to apply on top of its defaults"`
 
-func (s *Screen) show() {
-	(*s.s).Show()
-}
+	ReadOnly bool `long:"read-only" description:"Disable every action which changes the stack"`
+	Plain    bool `long:"plain" description:"Print events as lines of text rather than drawing the terminal UI, and exit once the operation finishes (default when standard output is not a terminal)"`
 
-func (s *Screen) PollEvent() tcell.Event {
-	return (*s.s).PollEvent()
-}
+	CDKOut string `long:"cdk-out" description:"CDK cloud assembly directory, e.g. cdk.out, used to find the stack and label resources by construct path"`
 
-func (s *Screen) Sync() {
-	(*s.s).Sync()
-}
+	Reports []string `long:"report" description:"Write a report when the operation finishes: JUnit XML to a .xml file, or a Markdown summary to a .md file, may be repeated"`
 
-func (s *Screen) clear() {
-	(*s.s).Clear()
-}
+	Record     bool   `long:"record" description:"Keep a record of each operation seen to finish in the local history store, for the history command"`
+	HistoryDir string `long:"history-dir" description:"Directory of the local history store (default: $XDG_DATA_HOME/cflivestatus/history)"`
 
-// sort interface
-type byName []fetcher.StackResource
+	LogFile string `long:"log-file" description:"Also write log output to this file; while the terminal UI is shown, logs are only kept here and in the log pane"`
 
-func (n byName) Len() int           { return len(n) }
-func (n byName) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
-func (n byName) Less(i, j int) bool { return n[i].Resource < n[j].Resource }
+	MetricsAddr string `long:"metrics-addr" description:"Serve Prometheus metrics at /metrics on this address, e.g. :9090"`
 
-func longestResourceName(res []fetcher.StackResource) int {
-	if len(res) == 0 {
-		return 0
-	}
-	m := len(res[0].Resource)
-	for _, r := range res {
-		if len(r.Resource) > m {
-			m = len(r.Resource)
-		}
-	}
-	return m
+	Serve   serveCommand   `command:"serve" description:"Serve a live web dashboard for the stack"`
+	Deploy  deployCommand  `command:"deploy" description:"Deploy a template through a change set, then watch the stack" long-description:"Deploy a template through a change set, then watch the stack. The template is sent inline, so may be at most 51,200 bytes; deploy larger templates from S3 with another tool wrapped by run, such as aws cloudformation deploy --s3-bucket."`
+	Run     runCommand     `command:"run" description:"Run a deploy command and watch the stack it deploys" long-description:"Run a deploy command and watch the stack it deploys. The command has no standard input, so cannot ask for approval: pass options such as cdk deploy --require-approval never, or sam deploy --no-confirm-changeset."`
+	History historyCommand `command:"history" description:"Show how long deployments of a stack have taken over time, and which resources were slower than usual"`
+	Config  configCommand  `command:"config" description:"Inspect the configuration"`
 }
 
-func (s *Screen) Render(statuses []fetcher.StackResource) {
-	s.clear()
-	i := 0
-	now := time.Now()
-	s.write(i, defStyle, "%s", now.Format(time.RFC1123Z))
-	i++
-
-	sort.Sort(byName(statuses))
-	nameLength := longestResourceName(statuses)
-
-	for _, r := range statuses {
-		if r.Reason != "" {
-			fs := fmt.Sprintf("%%%ds: %%s (%%s)", nameLength)
-			s.write(i, resourceStyle(r.Status), fs, r.Resource, r.Status, r.Reason)
-		} else {
-			fs := fmt.Sprintf("%%%ds: %%s", nameLength)
-			s.write(i, resourceStyle(r.Status), fs, r.Resource, r.Status)
-		}
-		i++
-	}
-	s.show()
+// snapshotter fetches the current state of a stack.
+type snapshotter interface {
+	Snapshot(ctx context.Context) (*fetcher.Snapshot, error)
 }
 
-func resourceStyle(status types.ResourceStatus) tcell.Style {
-	var style tcell.Style
-	switch status {
-	case types.ResourceStatusCreateComplete,
-		types.ResourceStatusUpdateComplete,
-		types.ResourceStatusDeleteComplete,
-		types.ResourceStatusRollbackComplete:
-		style = okStyle
-	case types.ResourceStatusCreateInProgress,
-		types.ResourceStatusUpdateInProgress,
-		types.ResourceStatusDeleteInProgress,
-		types.ResourceStatusRollbackInProgress:
-		style = updatingStyle
-	case types.ResourceStatusCreateFailed,
-		types.ResourceStatusUpdateFailed,
-		types.ResourceStatusDeleteFailed,
-		types.ResourceStatusRollbackFailed:
-		style = failedStyle
-	default:
-		style = defStyle
-	}
-	return style
+// app holds the state shared by every mode of operation.
+type app struct {
+	opts      *options
+	stackName string
+	identity  identity
+	settings  *settings.File
+	fetcher   snapshotter
+	metrics   *metrics.Metrics
+	// controller is nil in read only mode.
+	controller stackController
+	// waitForStack is set when the stack may not have been created yet.
+	waitForStack bool
+	// paths maps logical IDs to CDK construct paths, if known.
+	paths   map[string]string
+	reports *reporter
+	logs    *logging
+	// plain is set to print events as text instead of drawing the UI.
+	plain       bool
+	stackEvents plain.EventSource
+	history     eventHistory
+	// polling controls how often the stack is polled.
+	polling *pollControl
+	// recorder is nil unless operations are being recorded.
+	recorder *recorder
+	// expectOperation is set when an operation has been started but may
+	// not show on the stack yet.
+	expectOperation bool
 }
 
 func main() {
@@ -158,18 +113,22 @@ func main() {
 
 	ctx := context.TODO()
 
-	var opts struct {
-		SleepTime time.Duration `short:"s" long:"sleep-time" required:"no" default:"0"`
-		Verbose   []bool        `short:"v" long:"verbose" description:"Print verbose logging output"`
-		Args      struct {
-			Name string `required:"yes" positional-arg-name:"stack-name"`
-		} `positional-args:"yes" required:"yes"`
+	var opts options
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.SubcommandsOptional = true
+	parser.Usage = "[OPTIONS] [stack-name]"
+
+	file, configValues, err := loadSettings(parser, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading configuration: %v\n", err)
+		os.Exit(1)
 	}
 
-	_, err := flags.Parse(&opts)
+	rest, err := parser.Parse()
 	if err != nil {
 		os.Exit(1)
 	}
+	mergeMapSettings(parser, configValues)
 	switch len(opts.Verbose) {
 	case 0:
 		zerolog.SetGlobalLevel(zerolog.WarnLevel)
@@ -179,75 +138,247 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 
+	logs, err := setupLogging(opts.LogFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
 	log.Debug().Msgf("%s starting", os.Args[0])
 	log.Debug().Interface("opts", opts).Msg("parsed command line options")
 
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		log.Err(err).Msg("error loading default config")
+	command := ""
+	if parser.Active != nil {
+		command = parser.Active.Name
 	}
 
-	svc := cloudformation.NewFromConfig(cfg)
-	f := fetcher.New(opts.Args.Name, svc)
+	var stackName string
+	switch command {
+	case "config":
+		showConfig(os.Stdout, parser, file, opts.ConfigProfile)
+		return
+	case "serve":
+		stackName = opts.Serve.Args.Name
+	case "history":
+		stackName = opts.History.Args.Name
+	case "deploy":
+		stackName = opts.Deploy.Stack
+	case "run":
+		stackName = opts.Run.Stack
+		if len(rest) == 0 {
+			parser.WriteHelp(os.Stderr)
+			os.Exit(1)
+		}
+	default:
+		// the stack may be found from the CDK app instead
+		if len(rest) > 1 || len(rest) == 0 && opts.CDKOut == "" {
+			parser.WriteHelp(os.Stderr)
+			os.Exit(1)
+		}
+		if len(rest) == 1 {
+			stackName = rest[0]
+		}
+	}
 
-	// update resources goroutine
-	eventsCh := make(chan []fetcher.StackResource)
-	go func() {
-		for {
-			resources, err := f.Fetch(ctx)
-			if err != nil {
-				if handleFetchResourceError(opts.Args.Name, err) {
-					log.Fatal().Err(err).Msg("a fatal error occurred")
-				}
+	var paths map[string]string
+	if opts.CDKOut != "" {
+		stackName, paths, err = loadCDKStack(opts.CDKOut, stackName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading CDK app: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-				log.Warn().Err(err).Msg("error when polling stack resources")
-				time.Sleep(opts.SleepTime)
-				continue
-			}
-			eventsCh <- resources
+	reports, err := newReporter(opts.Reports)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := loadAWSConfig(ctx, opts.AWS)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading AWS config")
+	}
+
+	id, err := callerIdentity(ctx, cfg)
+	if err != nil {
+		log.Warn().Err(err).Msg("could not determine AWS account")
+	}
 
-			time.Sleep(opts.SleepTime)
+	m := metrics.New()
+	if opts.MetricsAddr != "" {
+		if err := serveMetrics(opts.MetricsAddr, m); err != nil {
+			fmt.Fprintf(os.Stderr, "error serving metrics: %v\n", err)
+			os.Exit(1)
 		}
-	}()
+	}
 
-	<-eventsCh
+	svc := cloudformation.NewFromConfig(cfg, func(o *cloudformation.Options) {
+		o.APIOptions = append(o.APIOptions, m.AddMiddleware)
+	})
+
+	f := fetcher.New(stackName, svc)
+	a := &app{
+		opts:      &opts,
+		stackName: stackName,
+		identity:  id,
+		settings:  file,
+		fetcher:   f,
+		metrics:   m,
+		paths:     paths,
+		reports:   reports,
+		logs:      logs,
+
+		plain:       opts.Plain || !isTerminal(os.Stdout),
+		stackEvents: f,
+		history:     f,
+		polling:     newPollControl(opts.SleepTime),
+	}
+	if !opts.ReadOnly {
+		a.controller = f
+	}
+	if opts.Record {
+		a.recorder = &recorder{store: store.Open(a.historyDir()), events: f}
+	}
 
-	screen, err := NewScreen()
+	switch command {
+	case "serve":
+		err = a.serve(ctx)
+	case "history":
+		err = a.showHistory(ctx, os.Stdout)
+	case "deploy":
+		err = a.deploy(ctx, deploy.New(stackName, svc))
+	case "run":
+		var code int
+		code, err = a.run(ctx, rest)
+		if err == nil {
+			os.Exit(code)
+		}
+	default:
+		err = a.watch(ctx)
+	}
+	if errors.Is(err, errOperationFailed) {
+		os.Exit(1)
+	}
 	if err != nil {
-		panic(err)
+		log.Fatal().Err(err).Msg("a fatal error occurred")
 	}
+}
 
-	// background goroutine that sends events to the main render loop
-	done := make(chan struct{})
+// serveMetrics listens on addr before returning, so that a port which is
+// already in use is reported at startup, then serves metrics from m in the
+// background.
+func serveMetrics(addr string, m *metrics.Metrics) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	log.Info().Str("addr", ln.Addr().String()).Msg("serving metrics")
 	go func() {
-		for {
-			ev := screen.PollEvent()
-			switch ev := ev.(type) {
-			case *tcell.EventResize:
-				screen.Sync()
-			case *tcell.EventKey:
-				switch ev.Key() {
-				case tcell.KeyCtrlC, tcell.KeyEscape:
-					close(done)
-					return
-				case tcell.KeyCtrlL:
-					screen.Sync()
-				}
-			}
+		if err := http.Serve(ln, mux); err != nil {
+			log.Error().Err(err).Msg("error serving metrics")
 		}
 	}()
+	return nil
+}
 
+// poll fetches a snapshot of the stack, passing it to fn, every SleepTime
+// or as often as the UI asks, until a fatal error occurs or ctx is cancelled.
+func (a *app) poll(ctx context.Context, dispatcher *notify.Dispatcher, fn func(*fetcher.Snapshot)) {
 	for {
-		select {
-		case <-done:
-			screen.Quit()
+		snap, err := a.fetcher.Snapshot(ctx)
+		if ctx.Err() != nil {
 			return
-		case res := <-eventsCh:
-			screen.Render(res)
 		}
+		if a.waitForStack && errors.Is(err, fetcher.ErrStackNotFound) {
+			a.polling.wait(ctx, time.Second)
+			continue
+		}
+		if err != nil {
+			if handleFetchResourceError(a.stackName, err) {
+				log.Fatal().Err(err).Msg("a fatal error occurred")
+			}
+
+			log.Warn().Err(err).Msg("error when polling stack resources")
+			a.polling.wait(ctx, 0)
+			continue
+		}
+		a.metrics.Observe(snap)
+		dispatcher.Observe(ctx, snap)
+		a.reports.Observe(snap)
+		a.recorder.Observe(ctx, snap)
+		fn(snap)
+
+		a.polling.wait(ctx, 0)
 	}
 }
 
+// startPolling polls in the background, sending each snapshot on snaps, and
+// returns a function which stops polling and waits until it has, so that
+// the fetcher is no longer in use.
+func (a *app) startPolling(ctx context.Context, dispatcher *notify.Dispatcher, snaps chan<- *fetcher.Snapshot) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.poll(ctx, dispatcher, func(snap *fetcher.Snapshot) {
+			select {
+			case snaps <- snap:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// parseTriggers converts trigger names from the command line, where "none"
+// may be used to disable every trigger.
+func parseTriggers(names []string) ([]notify.Trigger, error) {
+	triggers := []notify.Trigger{}
+	for _, name := range names {
+		if name == "none" {
+			continue
+		}
+		t, err := notify.ParseTrigger(name)
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, nil
+}
+
+func newDispatcher(webhooks []string, templatePath string, notifyOn []string, stuckAfter time.Duration, extra ...notify.Notifier) (*notify.Dispatcher, error) {
+	triggers, err := parseTriggers(notifyOn)
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers := append([]notify.Notifier{}, extra...)
+	if len(webhooks) > 0 {
+		tmpl := ""
+		if templatePath != "" {
+			b, err := os.ReadFile(templatePath)
+			if err != nil {
+				return nil, fmt.Errorf("reading webhook template: %w", err)
+			}
+			tmpl = string(b)
+		}
+		wh, err := notify.NewWebhook(webhooks, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notify.Filter(wh, triggers))
+	}
+
+	return notify.NewDispatcher(notify.NewWatcher(stuckAfter), notifiers...), nil
+}
+
 // handleFetchResourceError returns whether the loop should break or not,
 // given the error supplied
 func handleFetchResourceError(name string, err error) bool {
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/metrics"
+)
+
+func TestServeMetricsPortInUse(t *testing.T) {
+	is := is.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	defer ln.Close()
+
+	err = serveMetrics(ln.Addr().String(), metrics.New())
+	is.True(err != nil)
+}
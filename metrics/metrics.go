@@ -0,0 +1,215 @@
+// Package metrics exposes the state of watched stacks in the Prometheus text
+// exposition format.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// throttleCodes are the error codes AWS uses when rate limiting requests.
+var throttleCodes = map[string]bool{
+	"Throttling":               true,
+	"ThrottlingException":      true,
+	"ThrottledException":       true,
+	"RequestLimitExceeded":     true,
+	"TooManyRequestsException": true,
+}
+
+type stackState struct {
+	snap        *fetcher.Snapshot
+	lastSuccess time.Time
+}
+
+// Metrics collects stack state and API usage.
+type Metrics struct {
+	mu     sync.Mutex
+	stacks map[string]*stackState
+
+	apiCalls     map[string]float64
+	apiErrors    map[string]float64
+	apiThrottles map[string]float64
+
+	now func() time.Time
+}
+
+func New() *Metrics {
+	return &Metrics{
+		stacks:       map[string]*stackState{},
+		apiCalls:     map[string]float64{},
+		apiErrors:    map[string]float64{},
+		apiThrottles: map[string]float64{},
+		now:          time.Now,
+	}
+}
+
+// Observe records a successful poll of a stack.
+func (m *Metrics) Observe(snap *fetcher.Snapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stacks[snap.Stack.Name] = &stackState{
+		snap:        snap,
+		lastSuccess: snap.Time,
+	}
+}
+
+// AddMiddleware installs a middleware on an AWS client which counts API
+// calls, errors and throttles. Each retry attempt is counted separately.
+func (m *Metrics) AddMiddleware(stack *middleware.Stack) error {
+	mw := middleware.FinalizeMiddlewareFunc("cflivestatus/metrics", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		out, md, err := next.HandleFinalize(ctx, in)
+		m.recordCall(awsmiddleware.GetOperationName(ctx), err)
+		return out, md, err
+	})
+	return stack.Finalize.Add(mw, middleware.After)
+}
+
+func (m *Metrics) recordCall(operation string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.apiCalls[operation]++
+	if err == nil {
+		return
+	}
+	m.apiErrors[operation]++
+	var ae smithy.APIError
+	if errors.As(err, &ae) && throttleCodes[ae.ErrorCode()] {
+		m.apiThrottles[operation]++
+	}
+}
+
+// Handler serves the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = m.Write(w)
+	})
+}
+
+// Write renders every metric in the text exposition format.
+func (m *Metrics) Write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	e := &exposition{}
+
+	names := []string{}
+	for name := range m.stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e.header("cflivestatus_resources", "gauge", "Number of resources in each status.")
+	for _, name := range names {
+		counts := map[types.ResourceStatus]int{}
+		for _, r := range m.stacks[name].snap.Resources {
+			counts[r.Status]++
+		}
+		for _, status := range types.ResourceStatus("").Values() {
+			e.sample("cflivestatus_resources", float64(counts[status]), "stack", name, "status", string(status))
+		}
+	}
+
+	e.header("cflivestatus_stack_status", "gauge", "Current stack status, 1 for the active status and 0 otherwise.")
+	for _, name := range names {
+		current := m.stacks[name].snap.Stack.Status
+		for _, status := range types.StackStatus("").Values() {
+			v := 0.0
+			if status == current {
+				v = 1
+			}
+			e.sample("cflivestatus_stack_status", v, "stack", name, "status", string(status))
+		}
+	}
+
+	e.header("cflivestatus_operation_elapsed_seconds", "gauge", "Time since the in progress stack operation started, 0 when idle.")
+	for _, name := range names {
+		e.sample("cflivestatus_operation_elapsed_seconds", operationElapsed(m.stacks[name].snap.Stack, now).Seconds(), "stack", name)
+	}
+
+	e.header("cflivestatus_last_poll_age_seconds", "gauge", "Time since the stack was last polled successfully.")
+	for _, name := range names {
+		e.sample("cflivestatus_last_poll_age_seconds", now.Sub(m.stacks[name].lastSuccess).Seconds(), "stack", name)
+	}
+
+	for _, c := range []struct {
+		name, help string
+		values     map[string]float64
+	}{
+		{"cflivestatus_api_calls_total", "CloudFormation API requests made, including retries.", m.apiCalls},
+		{"cflivestatus_api_errors_total", "CloudFormation API requests which failed.", m.apiErrors},
+		{"cflivestatus_api_throttles_total", "CloudFormation API requests which were throttled.", m.apiThrottles},
+	} {
+		e.header(c.name, "counter", c.help)
+		ops := []string{}
+		for op := range m.apiCalls {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+		for _, op := range ops {
+			e.sample(c.name, c.values[op], "operation", op)
+		}
+	}
+
+	_, err := io.WriteString(w, e.String())
+	return err
+}
+
+// operationElapsed returns how long the current stack operation has been
+// running for.
+func operationElapsed(stack fetcher.Stack, now time.Time) time.Duration {
+	if !status.Stack(stack.Status).Active() {
+		return 0
+	}
+	started := stack.LastUpdated
+	if started.IsZero() {
+		started = stack.Created
+	}
+	if started.IsZero() {
+		return 0
+	}
+	return now.Sub(started)
+}
+
+type exposition struct {
+	strings.Builder
+}
+
+func (e *exposition) header(name, kind, help string) {
+	fmt.Fprintf(e, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a single value, with labels given as name/value pairs.
+func (e *exposition) sample(name string, value float64, labels ...string) {
+	e.WriteString(name)
+	if len(labels) > 0 {
+		e.WriteString("{")
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				e.WriteString(",")
+			}
+			fmt.Fprintf(e, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		e.WriteString("}")
+	}
+	fmt.Fprintf(e, " %g\n", value)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+func render(t *testing.T, m *Metrics) string {
+	var b strings.Builder
+	if err := m.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestStackMetrics(t *testing.T) {
+	is := is.New(t)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := New()
+	m.now = func() time.Time { return now }
+
+	m.Observe(&fetcher.Snapshot{
+		Time: now.Add(-5 * time.Second),
+		Stack: fetcher.Stack{
+			Name:        "stack",
+			Status:      types.StackStatusUpdateInProgress,
+			LastUpdated: now.Add(-2 * time.Minute),
+		},
+		Resources: []fetcher.StackResource{
+			{Resource: "A", Status: types.ResourceStatusUpdateInProgress},
+			{Resource: "B", Status: types.ResourceStatusUpdateInProgress},
+			{Resource: "C", Status: types.ResourceStatusCreateComplete},
+		},
+	})
+
+	out := render(t, m)
+	is.True(strings.Contains(out, `cflivestatus_resources{stack="stack",status="UPDATE_IN_PROGRESS"} 2`))
+	is.True(strings.Contains(out, `cflivestatus_resources{stack="stack",status="CREATE_COMPLETE"} 1`))
+	is.True(strings.Contains(out, `cflivestatus_resources{stack="stack",status="DELETE_FAILED"} 0`))
+	is.True(strings.Contains(out, `cflivestatus_stack_status{stack="stack",status="UPDATE_IN_PROGRESS"} 1`))
+	is.True(strings.Contains(out, `cflivestatus_stack_status{stack="stack",status="CREATE_COMPLETE"} 0`))
+	is.True(strings.Contains(out, `cflivestatus_operation_elapsed_seconds{stack="stack"} 120`))
+	is.True(strings.Contains(out, `cflivestatus_last_poll_age_seconds{stack="stack"} 5`))
+	is.True(strings.Contains(out, "# TYPE cflivestatus_resources gauge\n"))
+}
+
+func TestAPIMetrics(t *testing.T) {
+	is := is.New(t)
+
+	m := New()
+	m.recordCall("DescribeStacks", nil)
+	m.recordCall("DescribeStacks", &smithy.GenericAPIError{Code: "Throttling"})
+	m.recordCall("DescribeStackEvents", errors.New("connection reset"))
+
+	out := render(t, m)
+	is.True(strings.Contains(out, `cflivestatus_api_calls_total{operation="DescribeStacks"} 2`))
+	is.True(strings.Contains(out, `cflivestatus_api_errors_total{operation="DescribeStacks"} 1`))
+	is.True(strings.Contains(out, `cflivestatus_api_throttles_total{operation="DescribeStacks"} 1`))
+	is.True(strings.Contains(out, `cflivestatus_api_errors_total{operation="DescribeStackEvents"} 1`))
+	is.True(strings.Contains(out, `cflivestatus_api_throttles_total{operation="DescribeStackEvents"} 0`))
+}
+
+func TestLabelEscaping(t *testing.T) {
+	is := is.New(t)
+
+	e := &exposition{}
+	e.sample("metric", 1, "stack", `a"b\c`)
+	is.Equal(e.String(), `metric{stack="a\"b\\c"} 1`+"\n")
+}
@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+// Dispatcher watches snapshots and forwards the resulting events to a set of
+// notifiers.
+type Dispatcher struct {
+	watcher   *Watcher
+	notifiers []Notifier
+}
+
+func NewDispatcher(watcher *Watcher, notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{
+		watcher:   watcher,
+		notifiers: notifiers,
+	}
+}
+
+// Observe records the snapshot and delivers any events in the background,
+// so that slow notifiers do not hold up polling.
+func (d *Dispatcher) Observe(ctx context.Context, snap *fetcher.Snapshot) {
+	for _, e := range d.watcher.Observe(snap) {
+		log.Info().Str("trigger", string(e.Trigger)).Msg(e.Summary())
+		for _, n := range d.notifiers {
+			go func(n Notifier, e Event) {
+				if err := n.Notify(ctx, e); err != nil {
+					log.Warn().Err(err).Str("trigger", string(e.Trigger)).Msg("error sending notification")
+				}
+			}(n, e)
+		}
+	}
+}
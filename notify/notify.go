@@ -0,0 +1,212 @@
+// Package notify raises notifications when a watched stack reaches
+// interesting points in its deployment.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/simonrw/cflivestatus/failure"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// Trigger is a kind of state change that can raise a notification.
+type Trigger string
+
+const (
+	// TriggerFinished fires when the stack operation completes, successfully
+	// or otherwise.
+	TriggerFinished Trigger = "finished"
+	// TriggerFailure fires on the first resource failure of an operation.
+	TriggerFailure Trigger = "failure"
+	// TriggerRollback fires when the stack starts rolling back.
+	TriggerRollback Trigger = "rollback"
+	// TriggerStuck fires when a resource has been in progress for too long.
+	TriggerStuck Trigger = "stuck"
+)
+
+// Triggers lists every known trigger.
+var Triggers = []Trigger{TriggerFinished, TriggerFailure, TriggerRollback, TriggerStuck}
+
+// ParseTrigger converts a trigger name to a Trigger.
+func ParseTrigger(name string) (Trigger, error) {
+	for _, t := range Triggers {
+		if string(t) == name {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown trigger %q", name)
+}
+
+// Event is a single notification.
+type Event struct {
+	Trigger     Trigger   `json:"trigger"`
+	Stack       string    `json:"stack"`
+	StackStatus string    `json:"stack_status"`
+	Resource    string    `json:"resource,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Summary is a one line human readable description of the event.
+func (e Event) Summary() string {
+	switch e.Trigger {
+	case TriggerFinished:
+		return fmt.Sprintf("stack %s finished: %s", e.Stack, e.StackStatus)
+	case TriggerFailure:
+		return fmt.Sprintf("stack %s: resource %s failed: %s", e.Stack, e.Resource, e.Reason)
+	case TriggerRollback:
+		return fmt.Sprintf("stack %s is rolling back: %s", e.Stack, e.Reason)
+	case TriggerStuck:
+		return fmt.Sprintf("stack %s: resource %s has been %s since %s", e.Stack, e.Resource, e.Status, e.Time.Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("stack %s: %s", e.Stack, e.StackStatus)
+	}
+}
+
+// Notifier delivers events somewhere.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Filter wraps a notifier so that it only receives events for the given
+// triggers.
+func Filter(n Notifier, triggers []Trigger) Notifier {
+	f := filtered{n: n, triggers: map[Trigger]bool{}}
+	for _, t := range triggers {
+		f.triggers[t] = true
+	}
+	return f
+}
+
+type filtered struct {
+	n        Notifier
+	triggers map[Trigger]bool
+}
+
+func (f filtered) Notify(ctx context.Context, e Event) error {
+	if !f.triggers[e.Trigger] {
+		return nil
+	}
+	return f.n.Notify(ctx, e)
+}
+
+// Watcher compares consecutive snapshots and reports the events that
+// occurred between them.
+type Watcher struct {
+	stuckAfter time.Duration
+
+	prev   *fetcher.Snapshot
+	failed bool
+	stuck  map[string]time.Time
+}
+
+// NewWatcher creates a watcher. Resources are considered stuck when in
+// progress for longer than stuckAfter, or never if it is zero.
+func NewWatcher(stuckAfter time.Duration) *Watcher {
+	return &Watcher{
+		stuckAfter: stuckAfter,
+		stuck:      map[string]time.Time{},
+	}
+}
+
+// Observe records a new snapshot, returning any events caused by it. The
+// first snapshot only establishes the baseline.
+func (w *Watcher) Observe(snap *fetcher.Snapshot) []Event {
+	defer func() { w.prev = snap }()
+
+	var events []Event
+	newEvent := func(t Trigger) Event {
+		return Event{
+			Trigger:     t,
+			Stack:       snap.Stack.Name,
+			StackStatus: string(snap.Stack.Status),
+			Time:        snap.Time,
+		}
+	}
+
+	stackStatus := status.Stack(snap.Stack.Status)
+	if stackStatus.Active() && (w.prev == nil || !status.Stack(w.prev.Stack.Status).Active()) {
+		// a new operation has started, so failures may be reported again
+		w.failed = false
+	}
+
+	if w.prev != nil {
+		prevStatus := status.Stack(w.prev.Stack.Status)
+
+		// a rollback starts when an operation in progress stops going to
+		// plan, or when a failed rollback is continued
+		rollingBack := stackStatus.Active() && !status.Succeeding(snap.Stack.Status)
+		wasRollingBack := prevStatus.Active() && !status.Succeeding(w.prev.Stack.Status)
+		if rollingBack && !wasRollingBack {
+			e := newEvent(TriggerRollback)
+			e.Reason = snap.Stack.Reason
+			events = append(events, e)
+		}
+
+		if prevStatus.Active() && !stackStatus.Active() {
+			e := newEvent(TriggerFinished)
+			e.Reason = snap.Stack.Reason
+			events = append(events, e)
+		}
+
+		if !w.failed {
+			prevStatuses := map[string]types.ResourceStatus{}
+			for _, r := range w.prev.Resources {
+				prevStatuses[r.Resource] = r.Status
+			}
+			for _, r := range snap.Resources {
+				if status.Resource(r.Status) == status.Failed && !failure.Cancelled(r.Reason) && prevStatuses[r.Resource] != r.Status {
+					w.failed = true
+					e := newEvent(TriggerFailure)
+					e.Resource = r.Resource
+					e.Status = string(r.Status)
+					e.Reason = r.Reason
+					events = append(events, e)
+					break
+				}
+			}
+		}
+	}
+
+	if w.stuckAfter > 0 {
+		events = append(events, w.checkStuck(snap, newEvent)...)
+	}
+
+	return events
+}
+
+func (w *Watcher) checkStuck(snap *fetcher.Snapshot, newEvent func(Trigger) Event) []Event {
+	var events []Event
+	seen := map[string]bool{}
+	for _, r := range snap.Resources {
+		if !status.Resource(r.Status).Active() || r.Timestamp.IsZero() {
+			continue
+		}
+		seen[r.Resource] = true
+		if snap.Time.Sub(r.Timestamp) < w.stuckAfter {
+			continue
+		}
+		// only report each resource once per status change
+		if reported, ok := w.stuck[r.Resource]; ok && reported.Equal(r.Timestamp) {
+			continue
+		}
+		w.stuck[r.Resource] = r.Timestamp
+
+		e := newEvent(TriggerStuck)
+		e.Resource = r.Resource
+		e.Status = string(r.Status)
+		e.Time = r.Timestamp
+		events = append(events, e)
+	}
+	for name := range w.stuck {
+		if !seen[name] {
+			delete(w.stuck, name)
+		}
+	}
+	return events
+}
@@ -0,0 +1,228 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+func snapshot(at time.Time, status types.StackStatus, resources ...fetcher.StackResource) *fetcher.Snapshot {
+	return &fetcher.Snapshot{
+		Time: at,
+		Stack: fetcher.Stack{
+			Name:   "stack",
+			Status: status,
+		},
+		Resources: resources,
+	}
+}
+
+func triggers(events []Event) []Trigger {
+	out := []Trigger{}
+	for _, e := range events {
+		out = append(out, e.Trigger)
+	}
+	return out
+}
+
+func TestWatcherBaseline(t *testing.T) {
+	is := is.New(t)
+
+	w := NewWatcher(0)
+	now := time.Now()
+	events := w.Observe(snapshot(now, types.StackStatusUpdateRollbackComplete, fetcher.StackResource{
+		Resource: "Resource",
+		Status:   types.ResourceStatusUpdateFailed,
+	}))
+	is.Equal(len(events), 0)
+}
+
+func TestWatcherFailedDeploy(t *testing.T) {
+	is := is.New(t)
+
+	w := NewWatcher(0)
+	now := time.Now()
+
+	events := w.Observe(snapshot(now, types.StackStatusUpdateInProgress,
+		fetcher.StackResource{Resource: "A", Status: types.ResourceStatusUpdateInProgress},
+		fetcher.StackResource{Resource: "B", Status: types.ResourceStatusUpdateInProgress},
+	))
+	is.Equal(len(events), 0)
+
+	events = w.Observe(snapshot(now, types.StackStatusUpdateInProgress,
+		fetcher.StackResource{Resource: "A", Status: types.ResourceStatusUpdateFailed, Reason: "boom"},
+		fetcher.StackResource{Resource: "B", Status: types.ResourceStatusUpdateFailed, Reason: "cancelled"},
+	))
+	is.Equal(triggers(events), []Trigger{TriggerFailure})
+	is.Equal(events[0].Resource, "A")
+	is.Equal(events[0].Reason, "boom")
+
+	events = w.Observe(snapshot(now, types.StackStatusUpdateRollbackInProgress))
+	is.Equal(triggers(events), []Trigger{TriggerRollback})
+
+	events = w.Observe(snapshot(now, types.StackStatusUpdateRollbackCompleteCleanupInProgress))
+	is.Equal(len(events), 0)
+
+	events = w.Observe(snapshot(now, types.StackStatusUpdateRollbackComplete))
+	is.Equal(triggers(events), []Trigger{TriggerFinished})
+	is.Equal(events[0].StackStatus, "UPDATE_ROLLBACK_COMPLETE")
+}
+
+func TestWatcherContinuedRollback(t *testing.T) {
+	is := is.New(t)
+
+	w := NewWatcher(0)
+	now := time.Now()
+	w.Observe(snapshot(now, types.StackStatusUpdateRollbackFailed))
+
+	events := w.Observe(snapshot(now, types.StackStatusUpdateRollbackInProgress))
+	is.Equal(triggers(events), []Trigger{TriggerRollback})
+}
+
+func TestWatcherIgnoresCancellations(t *testing.T) {
+	is := is.New(t)
+
+	w := NewWatcher(0)
+	now := time.Now()
+
+	w.Observe(snapshot(now, types.StackStatusCreateInProgress,
+		fetcher.StackResource{Resource: "A", Status: types.ResourceStatusCreateInProgress},
+	))
+	events := w.Observe(snapshot(now, types.StackStatusCreateInProgress,
+		fetcher.StackResource{Resource: "A", Status: types.ResourceStatusCreateFailed, Reason: "Resource creation cancelled"},
+		fetcher.StackResource{Resource: "B", Status: types.ResourceStatusCreateFailed, Reason: "invalid property"},
+	))
+	is.Equal(triggers(events), []Trigger{TriggerFailure})
+	is.Equal(events[0].Resource, "B")
+}
+
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Notify(ctx context.Context, e Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestFilter(t *testing.T) {
+	is := is.New(t)
+
+	r := &recorder{}
+	n := Filter(r, []Trigger{TriggerFinished})
+	is.NoErr(n.Notify(context.Background(), Event{Trigger: TriggerFailure}))
+	is.NoErr(n.Notify(context.Background(), Event{Trigger: TriggerFinished}))
+	is.Equal(triggers(r.events), []Trigger{TriggerFinished})
+}
+
+func TestWatcherStuck(t *testing.T) {
+	is := is.New(t)
+
+	w := NewWatcher(10 * time.Minute)
+	started := time.Now()
+	resource := fetcher.StackResource{
+		Resource:  "Distribution",
+		Status:    types.ResourceStatusCreateInProgress,
+		Timestamp: started,
+	}
+
+	events := w.Observe(snapshot(started.Add(5*time.Minute), types.StackStatusCreateInProgress, resource))
+	is.Equal(len(events), 0)
+
+	events = w.Observe(snapshot(started.Add(11*time.Minute), types.StackStatusCreateInProgress, resource))
+	is.Equal(triggers(events), []Trigger{TriggerStuck})
+	is.Equal(events[0].Resource, "Distribution")
+
+	// only reported once
+	events = w.Observe(snapshot(started.Add(12*time.Minute), types.StackStatusCreateInProgress, resource))
+	is.Equal(len(events), 0)
+}
+
+func TestWebhookDefaultPayload(t *testing.T) {
+	is := is.New(t)
+
+	received := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Header.Get("Content-Type"), "application/json")
+		var e Event
+		is.NoErr(json.NewDecoder(r.Body).Decode(&e))
+		received <- e
+	}))
+	defer srv.Close()
+
+	wh, err := NewWebhook([]string{srv.URL}, "")
+	is.NoErr(err)
+
+	err = wh.Notify(context.Background(), Event{Trigger: TriggerFinished, Stack: "stack", StackStatus: "CREATE_COMPLETE"})
+	is.NoErr(err)
+
+	e := <-received
+	is.Equal(e.Trigger, TriggerFinished)
+	is.Equal(e.Stack, "stack")
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	is := is.New(t)
+
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		received <- string(b)
+	}))
+	defer srv.Close()
+
+	wh, err := NewWebhook([]string{srv.URL}, `{"text": {{ json .Summary }}}`)
+	is.NoErr(err)
+
+	err = wh.Notify(context.Background(), Event{Trigger: TriggerFinished, Stack: "stack", StackStatus: "CREATE_COMPLETE"})
+	is.NoErr(err)
+	is.Equal(<-received, `{"text": "stack stack finished: CREATE_COMPLETE"}`)
+}
+
+func TestWebhookRetries(t *testing.T) {
+	is := is.New(t)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	wh, err := NewWebhook([]string{srv.URL}, "")
+	is.NoErr(err)
+	wh.Backoff = time.Millisecond
+
+	is.NoErr(wh.Notify(context.Background(), Event{Trigger: TriggerFinished}))
+	is.Equal(calls, 3)
+}
+
+func TestWebhookClientErrorNotRetried(t *testing.T) {
+	is := is.New(t)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	wh, err := NewWebhook([]string{srv.URL}, "")
+	is.NoErr(err)
+	wh.Backoff = time.Millisecond
+
+	is.True(wh.Notify(context.Background(), Event{Trigger: TriggerFinished}) != nil)
+	is.Equal(calls, 1)
+}
@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// Webhook posts events as JSON to a list of URLs.
+type Webhook struct {
+	URLs    []string
+	Client  *http.Client
+	Retries int
+	Backoff time.Duration
+
+	tmpl *template.Template
+}
+
+// NewWebhook creates a webhook notifier. If tmpl is not empty it is parsed
+// as a text/template and executed with the Event to produce the request
+// body, otherwise the event itself is sent.
+func NewWebhook(urls []string, tmpl string) (*Webhook, error) {
+	w := &Webhook{
+		URLs:    urls,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		Retries: 3,
+		Backoff: time.Second,
+	}
+	if tmpl != "" {
+		t, err := template.New("webhook").Funcs(template.FuncMap{
+			"json": toJSON,
+		}).Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("parsing webhook template: %w", err)
+		}
+		w.tmpl = t
+	}
+	return w, nil
+}
+
+// toJSON renders a value as a JSON literal, so that templates can safely
+// embed arbitrary strings.
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (w *Webhook) payload(e Event) ([]byte, error) {
+	if w.tmpl == nil {
+		return json.Marshal(e)
+	}
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, e); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Notify sends the event to every configured URL, returning the first error
+// encountered once all URLs have been tried.
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	body, err := w.payload(e)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, url := range w.URLs {
+		if err := w.post(ctx, url, body); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (w *Webhook) post(ctx context.Context, url string, body []byte) error {
+	backoff := w.Backoff
+	var err error
+	for attempt := 0; attempt <= w.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = w.send(ctx, url, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return fmt.Errorf("posting to %s after %d attempts: %w", url, w.Retries+1, err)
+}
+
+// send makes a single request, returning whether a failure is worth retrying.
+func (w *Webhook) send(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("posting to %s: %w", url, err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 300 {
+		retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+		return retry, fmt.Errorf("posting to %s: unexpected status %s", url, res.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,163 @@
+// Package operation splits a stack's event log into the operations which
+// produced it, such as each deployment, so that past operations can be
+// looked at as if they had been watched.
+package operation
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// Operation is a single create, update, delete or import of a stack,
+// including any rollback.
+type Operation struct {
+	StackName string
+	StackID   string
+	// Token is the ClientRequestToken of the request which started the
+	// operation, if one was given.
+	Token string
+	Start time.Time
+	// End is when the stack settled, or zero if it has not yet.
+	End time.Time
+	// Status is the latest status of the stack during the operation.
+	Status types.StackStatus
+	Reason string
+	// Events are the operation's events, oldest first.
+	Events []fetcher.StackEvent
+	// Truncated is set when the event log does not go back as far as the
+	// start of the operation.
+	Truncated bool
+}
+
+// Split divides events, which are newest first as CloudFormation returns
+// them, into operations, newest first. A new operation starts when the stack
+// starts being created, updated, deleted or imported, or when it starts
+// changing again after settling, such as when a failed rollback is
+// continued by a new request.
+func Split(events []fetcher.StackEvent) []Operation {
+	var ops []Operation
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		// creating a change set for a new stack is not an operation on it
+		if e.StackLevel() && types.StackStatus(e.Status) == types.StackStatusReviewInProgress {
+			continue
+		}
+		if len(ops) == 0 || e.StackLevel() && startsOperation(ops[len(ops)-1], e) {
+			ops = append(ops, Operation{
+				StackName: e.Resource,
+				StackID:   e.StackID,
+				Token:     e.ClientRequestToken,
+				Start:     e.Timestamp,
+				// the oldest events may be part way through an operation
+				Truncated: len(ops) == 0 && !e.StartsOperation(),
+			})
+		}
+
+		op := &ops[len(ops)-1]
+		op.Events = append(op.Events, e)
+		if !e.StackLevel() {
+			continue
+		}
+		op.StackName = e.Resource
+		op.Status = types.StackStatus(e.Status)
+		op.Reason = e.Reason
+		if status.Finished(op.Status) {
+			op.End = e.Timestamp
+		} else {
+			op.End = time.Time{}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+func startsOperation(current Operation, e fetcher.StackEvent) bool {
+	if e.StartsOperation() {
+		return true
+	}
+	if !current.Finished() {
+		return false
+	}
+	active := status.Stack(types.StackStatus(e.Status)).Active()
+	return active || e.ClientRequestToken != "" && e.ClientRequestToken != current.Token
+}
+
+// Finished returns whether the stack settled at the end of the operation.
+func (o Operation) Finished() bool {
+	return !o.End.IsZero()
+}
+
+// Duration is how long the operation took, or has taken so far as of now.
+func (o Operation) Duration(now time.Time) time.Duration {
+	if o.Finished() {
+		return o.End.Sub(o.Start)
+	}
+	return now.Sub(o.Start)
+}
+
+// Kind is what the operation did, e.g. CREATE or UPDATE, or empty if the
+// start of the operation is not known.
+func (o Operation) Kind() string {
+	if o.Truncated || len(o.Events) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(string(o.Events[0].Status), "_IN_PROGRESS")
+}
+
+// Outcome classifies the stack's status at the end of the operation.
+func (o Operation) Outcome() status.Category {
+	return status.Stack(o.Status)
+}
+
+// Snapshot returns the state of the stack at the end of the operation.
+func (o Operation) Snapshot() *fetcher.Snapshot {
+	return o.SnapshotAt(len(o.Events))
+}
+
+// SnapshotAt returns the state of the stack once the first n events of the
+// operation had happened, rebuilt from the events, so that the operation
+// can be replayed. Resources are in the state of their latest event, and
+// the snapshot's events are newest first, as if they had been polled.
+func (o Operation) SnapshotAt(n int) *fetcher.Snapshot {
+	n = min(max(n, 0), len(o.Events))
+	snap := &fetcher.Snapshot{
+		Stack:     fetcher.Stack{Name: o.StackName, ID: o.StackID, Created: o.Start},
+		Resources: []fetcher.StackResource{},
+	}
+
+	index := map[string]int{}
+	for _, e := range o.Events[:n] {
+		snap.Time = e.Timestamp
+		snap.Events = append([]fetcher.StackEvent{e}, snap.Events...)
+		if e.StackLevel() {
+			snap.Stack.Status = types.StackStatus(e.Status)
+			snap.Stack.Reason = e.Reason
+			continue
+		}
+		if e.Hook != nil {
+			continue
+		}
+		r := fetcher.StackResource{
+			Resource:   e.Resource,
+			Type:       e.ResourceType,
+			PhysicalID: e.PhysicalID,
+			Status:     e.Status,
+			Reason:     e.Reason,
+			Timestamp:  e.Timestamp,
+		}
+		if i, ok := index[e.Resource]; ok {
+			snap.Resources[i] = r
+		} else {
+			index[e.Resource] = len(snap.Resources)
+			snap.Resources = append(snap.Resources, r)
+		}
+	}
+	return snap
+}
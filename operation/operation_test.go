@@ -0,0 +1,131 @@
+package operation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// history builds events from oldest to newest, returning them newest first
+// as CloudFormation does.
+type history []fetcher.StackEvent
+
+func (h *history) stack(after time.Duration, s types.StackStatus, token string) {
+	*h = append(*h, fetcher.StackEvent{
+		StackID:            "stack-id",
+		PhysicalID:         "stack-id",
+		Resource:           "app",
+		Status:             types.ResourceStatus(s),
+		Timestamp:          start.Add(after),
+		ClientRequestToken: token,
+	})
+}
+
+func (h *history) resource(after time.Duration, name string, s types.ResourceStatus, token string) {
+	*h = append(*h, fetcher.StackEvent{
+		StackID:            "stack-id",
+		Resource:           name,
+		ResourceType:       "AWS::SNS::Topic",
+		Status:             s,
+		Timestamp:          start.Add(after),
+		ClientRequestToken: token,
+	})
+}
+
+func (h history) events() []fetcher.StackEvent {
+	out := make([]fetcher.StackEvent, 0, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		out = append(out, h[i])
+	}
+	return out
+}
+
+func TestSplit(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	h.stack(0, types.StackStatusReviewInProgress, "")
+	h.stack(time.Minute, types.StackStatusCreateInProgress, "create")
+	h.resource(time.Minute+time.Second, "Topic", types.ResourceStatusCreateInProgress, "create")
+	h.resource(time.Minute+2*time.Second, "Topic", types.ResourceStatusCreateComplete, "create")
+	h.stack(time.Minute+3*time.Second, types.StackStatusCreateComplete, "create")
+
+	h.stack(time.Hour, types.StackStatusUpdateInProgress, "update")
+	h.resource(time.Hour+time.Second, "Topic", types.ResourceStatusUpdateFailed, "update")
+	h.stack(time.Hour+2*time.Second, types.StackStatusUpdateRollbackInProgress, "update")
+	h.resource(time.Hour+3*time.Second, "Topic", types.ResourceStatusUpdateFailed, "update")
+	h.stack(time.Hour+4*time.Second, types.StackStatusUpdateRollbackFailed, "update")
+
+	// continuing the rollback is a new request
+	h.stack(2*time.Hour, types.StackStatusUpdateRollbackInProgress, "continue")
+	h.stack(2*time.Hour+time.Second, types.StackStatusUpdateRollbackComplete, "continue")
+
+	ops := Split(h.events())
+	is.Equal(len(ops), 3)
+
+	is.Equal(ops[2].Kind(), "CREATE")
+	is.Equal(ops[2].Token, "create")
+	is.Equal(ops[2].StackName, "app")
+	is.Equal(len(ops[2].Events), 4)
+	is.Equal(ops[2].Duration(time.Time{}), 3*time.Second)
+	is.Equal(ops[2].Outcome(), status.Success)
+
+	is.Equal(ops[1].Kind(), "UPDATE")
+	is.Equal(ops[1].Status, types.StackStatusUpdateRollbackFailed)
+	is.Equal(ops[1].Outcome(), status.Failed)
+	is.Equal(len(ops[1].Events), 5)
+
+	is.Equal(ops[0].Token, "continue")
+	is.Equal(ops[0].Kind(), "UPDATE_ROLLBACK")
+	is.True(!ops[0].Truncated)
+	is.Equal(ops[0].Outcome(), status.RolledBack)
+}
+
+func TestSplitUnfinished(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	// the log does not reach back to the start of the first operation
+	h.resource(0, "Old", types.ResourceStatusUpdateComplete, "")
+	h.stack(time.Second, types.StackStatusUpdateComplete, "")
+	h.stack(time.Minute, types.StackStatusUpdateInProgress, "")
+	h.resource(time.Minute+time.Second, "Topic", types.ResourceStatusUpdateInProgress, "")
+
+	ops := Split(h.events())
+	is.Equal(len(ops), 2)
+	is.True(ops[1].Truncated)
+	is.Equal(ops[1].Kind(), "")
+	is.True(!ops[0].Finished())
+	is.Equal(ops[0].Duration(start.Add(2*time.Minute)), time.Minute)
+}
+
+func TestSnapshotAt(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	h.stack(0, types.StackStatusCreateInProgress, "")
+	h.resource(time.Second, "Topic", types.ResourceStatusCreateInProgress, "")
+	h.resource(2*time.Second, "Queue", types.ResourceStatusCreateInProgress, "")
+	h.resource(3*time.Second, "Topic", types.ResourceStatusCreateComplete, "")
+	h.stack(4*time.Second, types.StackStatusCreateComplete, "")
+	op := Split(h.events())[0]
+
+	snap := op.SnapshotAt(4)
+	is.Equal(snap.Stack.Status, types.StackStatusCreateInProgress)
+	is.Equal(snap.Time, start.Add(3*time.Second))
+	is.Equal(len(snap.Resources), 2)
+	is.Equal(snap.Resources[0].Resource, "Topic")
+	is.Equal(snap.Resources[0].Status, types.ResourceStatusCreateComplete)
+	is.Equal(snap.Resources[1].Status, types.ResourceStatusCreateInProgress)
+	// newest first, as polled
+	is.Equal(snap.Events[0].Resource, "Topic")
+	is.Equal(len(snap.Events), 4)
+
+	is.Equal(op.Snapshot().Stack.Status, types.StackStatusCreateComplete)
+}
@@ -0,0 +1,151 @@
+// Package plain prints the events of a stack as lines of text, for when
+// there is no terminal to draw on, such as in CI.
+package plain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/failure"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// nestedStackType is the resource type of a stack nested in another.
+const nestedStackType = "AWS::CloudFormation::Stack"
+
+// EventSource fetches the events of nested stacks.
+type EventSource interface {
+	StackEvents(ctx context.Context, stack string) ([]fetcher.StackEvent, error)
+}
+
+// Printer writes each new stack event as a line of text. Once a nested
+// stack settles, its own events are written beneath it.
+type Printer struct {
+	w      io.Writer
+	events EventSource
+	github bool
+
+	// seen holds the IDs of events already written, and is nil until the
+	// first snapshot.
+	seen map[string]bool
+}
+
+// New creates a printer. With github set, failures and rollbacks are also
+// written as GitHub Actions workflow commands, which annotate the run, and
+// the events of each nested stack are folded into a group.
+func New(w io.Writer, events EventSource, github bool) *Printer {
+	return &Printer{w: w, events: events, github: github}
+}
+
+// Observe writes the events which are new since the previous snapshot. For
+// the first snapshot, those of the current operation so far are written.
+func (p *Printer) Observe(ctx context.Context, snap *fetcher.Snapshot) {
+	var events []fetcher.StackEvent
+	if p.seen == nil {
+		p.seen = map[string]bool{}
+		events = failure.CurrentOperation(snap.Events)
+		for _, e := range snap.Events {
+			p.seen[e.ID] = true
+		}
+	} else {
+		for i := len(snap.Events) - 1; i >= 0; i-- {
+			if e := snap.Events[i]; !p.seen[e.ID] {
+				p.seen[e.ID] = true
+				events = append(events, e)
+			}
+		}
+	}
+
+	for _, e := range events {
+		p.event(ctx, e, 0)
+	}
+}
+
+func (p *Printer) event(ctx context.Context, e fetcher.StackEvent, depth int) {
+	indent := strings.Repeat("  ", depth)
+	line := fmt.Sprintf("%s%s %s", indent, e.Timestamp.Local().Format("15:04:05"), e.Resource)
+	if e.Hook != nil {
+		line += fmt.Sprintf(" hook %s %s", e.Hook.Type, e.Hook.Status)
+		if e.Hook.Reason != "" {
+			line += ": " + e.Hook.Reason
+		}
+		fmt.Fprintln(p.w, line)
+		return
+	}
+	if !e.StackLevel() {
+		line += " " + e.ResourceType
+	}
+	line += " " + string(e.Status)
+	if e.Reason != "" {
+		line += ": " + e.Reason
+	}
+	fmt.Fprintln(p.w, line)
+
+	if p.github {
+		p.annotate(e)
+	}
+	if e.ResourceType == nestedStackType && !e.StackLevel() && e.PhysicalID != "" && status.Resource(e.Status).Settled() {
+		p.nested(ctx, e, depth)
+	}
+}
+
+// nested writes the events of the latest operation on a nested stack.
+func (p *Printer) nested(ctx context.Context, e fetcher.StackEvent, depth int) {
+	events, err := p.events.StackEvents(ctx, e.PhysicalID)
+	if err != nil {
+		log.Warn().Err(err).Str("stack", e.Resource).Msg("could not fetch events of nested stack")
+		return
+	}
+
+	// groups cannot be nested, so deeper stacks are only indented
+	group := p.github && depth == 0
+	if group {
+		fmt.Fprintf(p.w, "::group::Nested stack %s %s\n", e.Resource, e.Status)
+	} else {
+		fmt.Fprintf(p.w, "%sNested stack %s:\n", strings.Repeat("  ", depth), e.Resource)
+	}
+	for _, ne := range failure.CurrentOperation(events) {
+		p.event(ctx, ne, depth+1)
+	}
+	if group {
+		fmt.Fprintln(p.w, "::endgroup::")
+	}
+}
+
+// annotate writes workflow commands for failures and rollbacks, which
+// GitHub Actions shows on the summary of the run.
+func (p *Printer) annotate(e fetcher.StackEvent) {
+	category := status.Resource(e.Status)
+	switch {
+	case category == status.Failed && !e.StackLevel() && failure.Cancelled(e.Reason):
+		p.command("warning", fmt.Sprintf("%s %s", e.Resource, e.Status), e.Reason)
+	case category == status.Failed:
+		p.command("error", fmt.Sprintf("%s %s", e.Resource, e.Status), e.Reason)
+	case category == status.RollingBack && e.StackLevel():
+		p.command("warning", fmt.Sprintf("%s is rolling back", e.Resource), e.Reason)
+	case category == status.RolledBack && e.StackLevel():
+		p.command("error", fmt.Sprintf("%s %s", e.Resource, e.Status), "the operation failed and was rolled back")
+	}
+}
+
+func (p *Printer) command(name, title, message string) {
+	if message == "" {
+		message = title
+	}
+	fmt.Fprintf(p.w, "::%s title=%s::%s\n", name, escapeProperty(title), escapeData(message))
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property of a workflow command, which also
+// cannot contain the separators between properties.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,109 @@
+package plain
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// clock is how a timestamp is printed.
+func clock(after time.Duration) string {
+	return start.Add(after).Local().Format("15:04:05")
+}
+
+// history builds events oldest first, and returns them newest first as
+// CloudFormation does.
+type history []fetcher.StackEvent
+
+func (h *history) add(e fetcher.StackEvent) {
+	e.ID = string(rune('a' + len(*h)))
+	*h = append(*h, e)
+}
+
+func (h *history) stack(after time.Duration, s types.ResourceStatus, reason string) {
+	h.add(fetcher.StackEvent{StackID: "stack-id", PhysicalID: "stack-id", Resource: "app", ResourceType: nestedStackType, Status: s, Reason: reason, Timestamp: start.Add(after)})
+}
+
+func (h *history) resource(after time.Duration, name, typ string, s types.ResourceStatus, reason string) {
+	h.add(fetcher.StackEvent{StackID: "stack-id", Resource: name, ResourceType: typ, Status: s, Reason: reason, Timestamp: start.Add(after)})
+}
+
+func (h history) events() []fetcher.StackEvent {
+	out := make([]fetcher.StackEvent, 0, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		out = append(out, h[i])
+	}
+	return out
+}
+
+type nestedEvents map[string][]fetcher.StackEvent
+
+func (n nestedEvents) StackEvents(ctx context.Context, stack string) ([]fetcher.StackEvent, error) {
+	return n[stack], nil
+}
+
+func TestOnlyNewEvents(t *testing.T) {
+	is := is.New(t)
+
+	var h history
+	h.stack(0, types.ResourceStatusUpdateInProgress, "")
+	h.resource(time.Second, "Old", "AWS::SNS::Topic", types.ResourceStatusUpdateComplete, "")
+	h.stack(2*time.Second, types.ResourceStatus(types.StackStatusUpdateComplete), "")
+	h.stack(10*time.Second, types.ResourceStatusUpdateInProgress, "User Initiated")
+	h.resource(11*time.Second, "Bucket", "AWS::S3::Bucket", types.ResourceStatusUpdateInProgress, "")
+
+	var b bytes.Buffer
+	p := New(&b, nestedEvents{}, false)
+	p.Observe(context.Background(), &fetcher.Snapshot{Events: h.events()})
+	is.Equal(b.String(), strings.Join([]string{
+		clock(10*time.Second) + " app UPDATE_IN_PROGRESS: User Initiated",
+		clock(11*time.Second) + " Bucket AWS::S3::Bucket UPDATE_IN_PROGRESS",
+		"",
+	}, "\n"))
+
+	b.Reset()
+	h.resource(12*time.Second, "Bucket", "AWS::S3::Bucket", types.ResourceStatusUpdateComplete, "")
+	p.Observe(context.Background(), &fetcher.Snapshot{Events: h.events()})
+	is.Equal(b.String(), clock(12*time.Second)+" Bucket AWS::S3::Bucket UPDATE_COMPLETE\n")
+}
+
+func TestGitHubAnnotations(t *testing.T) {
+	is := is.New(t)
+
+	var nested history
+	nested.add(fetcher.StackEvent{StackID: "nested-id", PhysicalID: "nested-id", Resource: "app-Data", Status: types.ResourceStatusCreateInProgress, Timestamp: start.Add(time.Second)})
+	nested.add(fetcher.StackEvent{StackID: "nested-id", Resource: "Table", ResourceType: "AWS::DynamoDB::Table", Status: types.ResourceStatusCreateFailed, Reason: "limit, exceeded: 100%", Timestamp: start.Add(2 * time.Second)})
+
+	var h history
+	h.stack(0, types.ResourceStatusCreateInProgress, "")
+	h.resource(3*time.Second, "Data", nestedStackType, types.ResourceStatusCreateFailed, "Embedded stack failed")
+	h.resource(3*time.Second, "Queue", "AWS::SQS::Queue", types.ResourceStatusCreateFailed, "Resource creation cancelled")
+	h[len(h)-2].PhysicalID = "nested-id"
+	h.stack(4*time.Second, types.ResourceStatusRollbackInProgress, "The following resource(s) failed to create: [Data]")
+
+	var b bytes.Buffer
+	p := New(&b, nestedEvents{"nested-id": nested.events()}, true)
+	p.Observe(context.Background(), &fetcher.Snapshot{Events: h.events()})
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	is.Equal(lines[2], "::error title=Data CREATE_FAILED::Embedded stack failed")
+	is.Equal(lines[3], "::group::Nested stack Data CREATE_FAILED")
+	is.Equal(lines[4], "  "+clock(time.Second)+" app-Data CREATE_IN_PROGRESS")
+	is.Equal(lines[6], "::error title=Table CREATE_FAILED::limit, exceeded: 100%25")
+	is.Equal(lines[7], "::endgroup::")
+	is.Equal(lines[9], "::warning title=Queue CREATE_FAILED::Resource creation cancelled")
+	is.Equal(lines[11], "::warning title=app is rolling back::The following resource(s) failed to create: [Data]")
+}
+
+func TestEscapeProperty(t *testing.T) {
+	is := is.New(t)
+	is.Equal(escapeProperty("a: b, 50%\n"), "a%3A b%2C 50%25%0A")
+}
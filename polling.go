@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// pollIntervals are the steps the poll interval moves through when it is
+// changed while watching.
+var pollIntervals = []time.Duration{0, time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second, time.Minute}
+
+// pollControl lets the UI pause polling, ask for a poll straight away and
+// change how often the stack is polled.
+type pollControl struct {
+	mu       sync.Mutex
+	paused   bool
+	interval time.Duration
+
+	// now asks for a poll straight away, and changed for the wait to be
+	// recalculated.
+	now     chan struct{}
+	changed chan struct{}
+}
+
+func newPollControl(interval time.Duration) *pollControl {
+	return &pollControl{
+		interval: interval,
+		now:      make(chan struct{}, 1),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// wait blocks until the next poll is due, after the interval or minimum,
+// whichever is longer. While paused, it only returns once polling resumes
+// or a poll is asked for.
+func (p *pollControl) wait(ctx context.Context, minimum time.Duration) {
+	for {
+		p.mu.Lock()
+		paused, interval := p.paused, max(p.interval, minimum)
+		p.mu.Unlock()
+
+		if !paused && interval <= 0 {
+			return
+		}
+		var due <-chan time.Time
+		var timer *time.Timer
+		if !paused {
+			timer = time.NewTimer(interval)
+			due = timer.C
+		}
+		changed := false
+		select {
+		case <-ctx.Done():
+		case <-p.now:
+		case <-due:
+		case <-p.changed:
+			changed = true
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// Refresh asks for a poll straight away, even while paused.
+func (p *pollControl) Refresh() {
+	signal(p.now)
+}
+
+// TogglePause pauses or resumes polling, returning whether it is now
+// paused. Polling resumes with a poll straight away, as the view may be
+// well out of date.
+func (p *pollControl) TogglePause() bool {
+	p.mu.Lock()
+	p.paused = !p.paused
+	paused := p.paused
+	p.mu.Unlock()
+	if paused {
+		signal(p.changed)
+	} else {
+		signal(p.now)
+	}
+	return paused
+}
+
+// Step moves the interval to the next longer step, or the next shorter one
+// if delta is negative, returning a description of the new interval.
+func (p *pollControl) Step(delta int) string {
+	p.mu.Lock()
+	if delta > 0 {
+		for _, d := range pollIntervals {
+			if d > p.interval {
+				p.interval = d
+				break
+			}
+		}
+	} else {
+		next := time.Duration(0)
+		for _, d := range pollIntervals {
+			if d < p.interval {
+				next = d
+			}
+		}
+		p.interval = next
+	}
+	interval := p.interval
+	p.mu.Unlock()
+	signal(p.changed)
+
+	if interval == 0 {
+		return "polling continuously"
+	}
+	return fmt.Sprintf("polling every %s", formatAge(interval))
+}
+
+// signal notifies whoever is waiting on c, if nobody has been already.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
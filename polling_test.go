@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/matryer/is"
+)
+
+// waitAsync starts waiting for the next poll, returning a channel closed
+// once the wait is over.
+func waitAsync(ctx context.Context, p *pollControl, minimum time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		p.wait(ctx, minimum)
+		close(done)
+	}()
+	return done
+}
+
+func returned(done <-chan struct{}, within time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(within):
+		return false
+	}
+}
+
+func TestPollStep(t *testing.T) {
+	is := is.New(t)
+
+	p := newPollControl(0)
+	is.Equal(p.Step(-1), "polling continuously") // already the shortest
+	is.Equal(p.Step(1), "polling every 1s")
+	is.Equal(p.Step(1), "polling every 2s")
+	for range pollIntervals {
+		p.Step(1)
+	}
+	is.Equal(p.Step(1), "polling every 1m00s") // already the longest
+	is.Equal(p.Step(-1), "polling every 30s")
+
+	// an interval from the command line moves to the nearest step
+	p = newPollControl(3 * time.Second)
+	is.Equal(p.Step(1), "polling every 5s")
+	p = newPollControl(3 * time.Second)
+	is.Equal(p.Step(-1), "polling every 2s")
+}
+
+func TestPollWaitInterval(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	is.True(returned(waitAsync(ctx, newPollControl(0), 0), time.Second))
+
+	// the minimum applies even when polling continuously
+	start := time.Now()
+	newPollControl(0).wait(ctx, 20*time.Millisecond)
+	is.True(time.Since(start) >= 20*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := waitAsync(ctx, newPollControl(time.Hour), 0)
+	cancel()
+	is.True(returned(done, time.Second))
+}
+
+func TestPollRefresh(t *testing.T) {
+	is := is.New(t)
+
+	p := newPollControl(time.Hour)
+	done := waitAsync(context.Background(), p, 0)
+	p.Refresh()
+	is.True(returned(done, time.Second))
+}
+
+func TestPollPause(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	p := newPollControl(0)
+	is.True(p.TogglePause())
+	done := waitAsync(ctx, p, 0)
+	is.True(!returned(done, 50*time.Millisecond))
+
+	// a refresh polls once while paused, and polling stays paused
+	p.Refresh()
+	is.True(returned(done, time.Second))
+	done = waitAsync(ctx, p, 0)
+	is.True(!returned(done, 50*time.Millisecond))
+
+	// resuming polls straight away
+	is.True(!p.TogglePause())
+	is.True(returned(done, time.Second))
+}
+
+func TestPollIntervalChangedWhileWaiting(t *testing.T) {
+	is := is.New(t)
+
+	p := newPollControl(time.Minute)
+	done := waitAsync(context.Background(), p, 0)
+	is.True(!returned(done, 50*time.Millisecond))
+
+	// the wait is worked out again for the new interval
+	for range pollIntervals {
+		p.Step(-1)
+	}
+	is.True(returned(done, time.Second))
+}
+
+func TestHelpKeys(t *testing.T) {
+	is := is.New(t)
+
+	s := &Screen{}
+	is.True(!dismissesHelp(s, key('x')))
+
+	km, err := newKeymap(nil)
+	is.NoErr(err)
+	s.ToggleHelp(km.help())
+	is.True(s.ShowingHelp())
+	is.True(dismissesHelp(s, key('p')))
+	is.True(dismissesHelp(s, tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone)))
+	is.True(!dismissesHelp(s, tcell.NewEventKey(tcell.KeyCtrlC, 0, tcell.ModCtrl)))
+
+	s.ToggleHelp(nil)
+	is.True(!s.ShowingHelp())
+}
+
+func TestHelpListsBindings(t *testing.T) {
+	is := is.New(t)
+
+	km, err := newKeymap(map[string]string{"pause": "P"})
+	is.NoErr(err)
+	help := km.help()
+	is.Equal(len(help), len(actionHelp))
+	found := false
+	for _, line := range help {
+		if line[0] == 'P' {
+			found = true
+		}
+	}
+	is.True(found)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/simonrw/cflivestatus/theme"
+)
+
+// prompt is a question drawn over the screen, which takes every key press
+// until it is answered. With options it is a multi-select list, otherwise it
+// is a yes or no question which defaults to no.
+type prompt struct {
+	question string
+	options  []string
+	selected map[int]bool
+	cursor   int
+
+	// answer is called with the selected options once the prompt is
+	// accepted, and may return a further prompt to show.
+	answer func(selected []string) *prompt
+}
+
+func confirm(question string, yes func() *prompt) *prompt {
+	return &prompt{
+		question: question,
+		answer:   func([]string) *prompt { return yes() },
+	}
+}
+
+func choose(question string, options []string, answer func(selected []string) *prompt) *prompt {
+	return &prompt{
+		question: question,
+		options:  options,
+		selected: map[int]bool{},
+		answer:   answer,
+	}
+}
+
+// handle processes a key press, returning the prompt to show next, which is
+// nil once the prompt has been answered or dismissed.
+func (p *prompt) handle(ev *tcell.EventKey) *prompt {
+	if ev.Key() == tcell.KeyEscape {
+		return nil
+	}
+
+	if p.options == nil {
+		if ev.Key() == tcell.KeyRune && (ev.Rune() == 'y' || ev.Rune() == 'Y') {
+			return p.answer(nil)
+		}
+		return nil
+	}
+
+	switch {
+	case ev.Key() == tcell.KeyUp || ev.Key() == tcell.KeyRune && ev.Rune() == 'k':
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case ev.Key() == tcell.KeyDown || ev.Key() == tcell.KeyRune && ev.Rune() == 'j':
+		if p.cursor < len(p.options)-1 {
+			p.cursor++
+		}
+	case ev.Key() == tcell.KeyRune && ev.Rune() == ' ':
+		p.selected[p.cursor] = !p.selected[p.cursor]
+	case ev.Key() == tcell.KeyEnter:
+		var selected []string
+		for i, o := range p.options {
+			if p.selected[i] {
+				selected = append(selected, o)
+			}
+		}
+		return p.answer(selected)
+	}
+	return p
+}
+
+func (p *prompt) hint() string {
+	if p.options == nil {
+		return "[y/N]"
+	}
+	return "space to select, enter to continue, esc to cancel"
+}
+
+// renderPrompt draws the prompt in a box over the middle of the screen.
+func (s *Screen) renderPrompt(p *prompt) {
+	lines := []string{p.question, ""}
+	for i, o := range p.options {
+		mark := "[ ]"
+		if p.selected[i] {
+			mark = "[x]"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", mark, o))
+	}
+	if len(p.options) > 0 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, p.hint())
+
+	highlight := -1
+	if len(p.options) > 0 {
+		highlight = 2 + p.cursor
+	}
+	s.renderBox(lines, highlight)
+}
+
+// renderBox draws lines in a box over the middle of the screen, with the
+// line at index highlight in reverse video.
+func (s *Screen) renderBox(lines []string, highlight int) {
+	width := 0
+	for _, l := range lines {
+		if n := len([]rune(l)); n > width {
+			width = n
+		}
+	}
+	screenWidth, screenHeight := (*s.s).Size()
+	left := (screenWidth - width - 4) / 2
+	top := (screenHeight - len(lines) - 2) / 2
+	if left < 0 {
+		left = 0
+	}
+	if top < 0 {
+		top = 0
+	}
+
+	style := s.theme.Style(theme.Default)
+	border := "+" + strings.Repeat("-", width+2) + "+"
+	s.writeAt(top, left, style, "%s", border)
+	for i, l := range lines {
+		lineStyle := style
+		if i == highlight {
+			lineStyle = style.Reverse(true)
+		}
+		col := s.writeAt(top+1+i, left, style, "| ")
+		col = s.writeAt(top+1+i, col, lineStyle, "%-*s", width, l)
+		s.writeAt(top+1+i, col, style, " |")
+	}
+	s.writeAt(top+1+len(lines), left, style, "%s", border)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/operation"
+	"github.com/simonrw/cflivestatus/status"
+	"github.com/simonrw/cflivestatus/store"
+)
+
+// trendWidth is the width of the longest bar in the duration trend.
+const trendWidth = 30
+
+type historyCommand struct {
+	Limit  int     `long:"limit" description:"Number of deployments to show" default:"20"`
+	Factor float64 `long:"factor" description:"How many times slower than its median a resource must be to count as a regression" default:"2"`
+	Args   struct {
+		Name string `required:"yes" positional-arg-name:"stack-name"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// recorder saves operations to the local history store once they are seen
+// to finish.
+type recorder struct {
+	store  *store.Store
+	events eventHistory
+
+	prev *fetcher.Snapshot
+}
+
+// Observe records the stack's operations if one finished since the
+// previous snapshot. A nil recorder does nothing.
+func (r *recorder) Observe(ctx context.Context, snap *fetcher.Snapshot) {
+	if r == nil {
+		return
+	}
+	if r.prev != nil && status.Stack(r.prev.Stack.Status).Active() && status.Finished(snap.Stack.Status) {
+		if _, err := r.save(ctx); err != nil {
+			log.Warn().Err(err).Msg("could not record the operation")
+		}
+	}
+	r.prev = snap
+}
+
+// save reads the stack's event log and stores every finished operation in
+// it, including any from before the stack was watched, returning how many
+// were not already stored.
+func (r *recorder) save(ctx context.Context) (int, error) {
+	records, err := finishedOperations(ctx, r.events)
+	if err != nil {
+		return 0, err
+	}
+	return r.store.Save(records...)
+}
+
+// finishedOperations returns a record of each finished operation in the
+// stack's event log.
+func finishedOperations(ctx context.Context, events eventHistory) ([]store.Record, error) {
+	history, err := events.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var records []store.Record
+	for _, op := range operation.Split(history) {
+		if rec, ok := store.FromOperation(op); ok {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// showHistory brings the store up to date from the stack's event log, then
+// writes how long its deployments have taken, and which resources were
+// unusually slow in the latest one.
+func (a *app) showHistory(ctx context.Context, w io.Writer) error {
+	r := &recorder{store: store.Open(a.historyDir()), events: a.history}
+	added, err := r.save(ctx)
+	if err != nil {
+		return err
+	}
+	log.Info().Int("added", added).Msg("recorded operations from the event log")
+
+	records, err := r.store.Load(a.stackName)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Fprintf(w, "No finished deployments of %s have been recorded.\n", a.stackName)
+		return nil
+	}
+	writeTrend(w, a.stackName, records, a.opts.History.Limit)
+
+	regressions := store.Regressions(records, a.opts.History.Factor)
+	if len(regressions) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\nSlower than usual in the latest deployment:\n")
+	for _, reg := range regressions {
+		fmt.Fprintf(w, "  %s (%s) took %.1fx longer than its median: %s, usually %s\n", reg.Resource, reg.Type, reg.Factor(), formatAge(reg.Duration), formatAge(reg.Median))
+	}
+	return nil
+}
+
+// writeTrend lists the most recent deployments with a bar showing how long
+// each took, and compares the latest with the median.
+func writeTrend(w io.Writer, stackName string, records []store.Record, limit int) {
+	all := records
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	longest := records[0].Duration()
+	for _, rec := range records {
+		longest = max(longest, rec.Duration())
+	}
+
+	fmt.Fprintf(w, "Deployments of %s, oldest first:\n\n", stackName)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  STARTED\tKIND\tSTATUS\tDURATION\t")
+	for _, rec := range records {
+		bar := 1
+		if longest > 0 {
+			bar = max(int(rec.Duration()*trendWidth/longest), 1)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", rec.Start.Local().Format("2006-01-02 15:04:05"), rec.Kind, rec.Status, formatAge(rec.Duration()), strings.Repeat("█", bar))
+	}
+	tw.Flush()
+
+	durations := make([]time.Duration, 0, len(all))
+	for _, rec := range all {
+		durations = append(durations, rec.Duration())
+	}
+	median := store.Median(durations)
+	latest := all[len(all)-1].Duration()
+	fmt.Fprintf(w, "\nMedian %s over %d deployments; the latest took %s", formatAge(median), len(all), formatAge(latest))
+	if median > 0 {
+		fmt.Fprintf(w, " (%.1fx the median)", float64(latest)/float64(median))
+	}
+	fmt.Fprintln(w, ".")
+}
+
+// historyDir is where the history store is kept.
+func (a *app) historyDir() string {
+	if a.opts.HistoryDir != "" {
+		return a.opts.HistoryDir
+	}
+	return store.DefaultDir()
+}
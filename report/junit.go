@@ -0,0 +1,97 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as JUnit XML, with a test case per resource.
+// Resources which failed at any point are failures, with their reasons.
+func WriteJUnit(w io.Writer, r Report) error {
+	suite := junitSuite{
+		Name: r.Stack,
+		Time: seconds(r.Duration()),
+	}
+	if !r.Start.IsZero() {
+		suite.Timestamp = r.Start.UTC().Format("2006-01-02T15:04:05")
+	}
+	for _, res := range r.Resources {
+		c := junitCase{
+			Name:      res.Name,
+			Classname: res.Type,
+			Time:      seconds(res.Duration),
+		}
+		switch {
+		case res.Failed():
+			c.Failure = &junitFailure{
+				Message: res.Failure.Reason,
+				Type:    string(res.Failure.Status),
+				Text:    fmt.Sprintf("%s %s at %s: %s", res.Name, res.Failure.Status, res.Failure.Timestamp.UTC().Format("2006-01-02T15:04:05Z"), res.Failure.Reason),
+			}
+			suite.Failures++
+		case res.Skipped():
+			c.Skipped = &junitSkipped{Message: string(res.Status)}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Tests = len(suite.Cases)
+
+	doc := junitSuites{
+		Name:     "cflivestatus",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// slowest is how many of the slowest resources the summary lists.
+const slowest = 5
+
+// WriteMarkdown writes a summary of the report suitable for posting as a
+// pull request comment: the outcome, duration, failures, slowest resources
+// and the stack's outputs.
+func WriteMarkdown(w io.Writer, r Report) error {
+	var b strings.Builder
+
+	outcome := "succeeded"
+	if !r.Succeeded() {
+		outcome = "failed"
+	}
+	fmt.Fprintf(&b, "## Deployment of `%s` %s\n\n", r.Stack, outcome)
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Status | `%s` |\n", r.Status)
+	if r.Reason != "" {
+		fmt.Fprintf(&b, "| Reason | %s |\n", cell(r.Reason))
+	}
+	if !r.Start.IsZero() {
+		fmt.Fprintf(&b, "| Started | %s |\n", r.Start.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	fmt.Fprintf(&b, "| Duration | %s |\n", formatDuration(r.Duration()))
+	fmt.Fprintf(&b, "| Resources | %d |\n", len(r.Resources))
+
+	if failures := r.Failures(); len(failures) > 0 {
+		fmt.Fprintf(&b, "\n### Failures\n\n")
+		for _, c := range r.RootCauses {
+			fmt.Fprintf(&b, "- **%s** (`%s`) `%s`: %s\n", c.Event.Resource, c.Event.ResourceType, c.Event.Status, c.Event.Reason)
+			if len(c.Cancelled) > 0 {
+				names := make([]string, 0, len(c.Cancelled))
+				for _, e := range c.Cancelled {
+					names = append(names, e.Resource)
+				}
+				fmt.Fprintf(&b, "  - %d cancelled as a result: %s\n", len(names), strings.Join(names, ", "))
+			}
+		}
+		if len(r.RootCauses) == 0 {
+			for _, res := range failures {
+				fmt.Fprintf(&b, "- **%s** (`%s`) `%s`: %s\n", res.Name, res.Type, res.Failure.Status, res.Failure.Reason)
+			}
+		}
+	}
+
+	if slow := r.Slowest(slowest); len(slow) > 0 {
+		fmt.Fprintf(&b, "\n### Slowest resources\n\n| Resource | Type | Duration |\n|---|---|---|\n")
+		for _, res := range slow {
+			fmt.Fprintf(&b, "| %s | `%s` | %s |\n", cell(res.Name), res.Type, formatDuration(res.Duration))
+		}
+	}
+
+	if len(r.Outputs) > 0 {
+		fmt.Fprintf(&b, "\n### Outputs\n\n| Key | Value | Description |\n|---|---|---|\n")
+		for _, o := range r.Outputs {
+			fmt.Fprintf(&b, "| %s | `%s` | %s |\n", cell(o.Key), cell(o.Value), cell(o.Description))
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// cell escapes text for a table cell, which cannot contain pipes or line
+// breaks.
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,199 @@
+// Package report summarises a stack operation once it has finished, in
+// formats which CI systems understand.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/simonrw/cflivestatus/failure"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// Report describes the most recent operation on a stack.
+type Report struct {
+	Stack      string
+	Status     types.StackStatus
+	Reason     string
+	Start      time.Time
+	End        time.Time
+	Resources  []Resource
+	RootCauses []failure.RootCause
+	Outputs    []fetcher.Output
+}
+
+// Resource is how a single resource fared during the operation.
+type Resource struct {
+	Name   string
+	Type   string
+	Status types.ResourceStatus
+	// Failure is the first failure of the resource during the operation,
+	// if any, which may since have been rolled back.
+	Failure *fetcher.StackEvent
+	// Duration is how long the resource spent in progress during the
+	// operation, including any rollback, or zero if it was not changed.
+	Duration time.Duration
+}
+
+// Failed returns whether the resource failed at any point in the operation.
+func (r Resource) Failed() bool {
+	return r.Failure != nil
+}
+
+// Skipped returns whether CloudFormation left the resource alone.
+func (r Resource) Skipped() bool {
+	return status.Resource(r.Status) == status.Skipped
+}
+
+// New builds a report from a snapshot of the stack. Resources which were
+// deleted during the operation are included from the stack's events.
+func New(snap *fetcher.Snapshot) Report {
+	r := Report{
+		Stack:      snap.Stack.Name,
+		Status:     snap.Stack.Status,
+		Reason:     snap.Stack.Reason,
+		Start:      snap.Stack.LastUpdated,
+		End:        snap.Time,
+		RootCauses: failure.Analyse(snap.Events),
+		Outputs:    snap.Stack.Outputs,
+	}
+	if r.Start.IsZero() {
+		r.Start = snap.Stack.Created
+	}
+
+	resources := map[string]*Resource{}
+	for _, sr := range snap.Resources {
+		resources[sr.Resource] = &Resource{Name: sr.Resource, Type: sr.Type, Status: sr.Status}
+	}
+
+	started := map[string]time.Time{}
+	opStarted := false
+	for _, e := range failure.CurrentOperation(snap.Events) {
+		if e.Hook != nil {
+			continue
+		}
+		if e.StackLevel() {
+			category := status.Stack(types.StackStatus(e.Status))
+			if category.Active() && !opStarted {
+				opStarted = true
+				r.Start = e.Timestamp
+			}
+			if category.Settled() {
+				r.End = e.Timestamp
+			}
+			continue
+		}
+
+		category := status.Resource(e.Status)
+		res, ok := resources[e.Resource]
+		if !ok {
+			res = &Resource{Name: e.Resource, Type: e.ResourceType, Status: e.Status}
+			resources[e.Resource] = res
+		}
+		if category == status.Failed && res.Failure == nil {
+			failed := e
+			res.Failure = &failed
+		}
+		if category.Active() {
+			if _, ok := started[e.Resource]; !ok {
+				started[e.Resource] = e.Timestamp
+			}
+		} else if start, ok := started[e.Resource]; ok {
+			res.Duration += e.Timestamp.Sub(start)
+			delete(started, e.Resource)
+		}
+	}
+	// resources still in progress have been so until the end
+	for name, start := range started {
+		resources[name].Duration += r.End.Sub(start)
+	}
+
+	for _, res := range resources {
+		r.Resources = append(r.Resources, *res)
+	}
+	sort.Slice(r.Resources, func(i, j int) bool { return r.Resources[i].Name < r.Resources[j].Name })
+	return r
+}
+
+// Duration is how long the operation took, or has taken so far.
+func (r Report) Duration() time.Duration {
+	if r.Start.IsZero() || r.End.Before(r.Start) {
+		return 0
+	}
+	return r.End.Sub(r.Start)
+}
+
+// Succeeded returns whether the operation completed without rolling back.
+func (r Report) Succeeded() bool {
+	return status.Stack(r.Status) == status.Success
+}
+
+// Failures returns the resources which failed during the operation.
+func (r Report) Failures() []Resource {
+	var out []Resource
+	for _, res := range r.Resources {
+		if res.Failed() {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// Slowest returns up to n resources which were changed, slowest first.
+func (r Report) Slowest(n int) []Resource {
+	var out []Resource
+	for _, res := range r.Resources {
+		if res.Duration > 0 {
+			out = append(out, res)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Duration > out[j].Duration })
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// writerFor chooses the format of a report file from its extension.
+func writerFor(path string) (func(io.Writer, Report) error, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		return WriteJUnit, nil
+	case ".md", ".markdown":
+		return WriteMarkdown, nil
+	default:
+		return nil, fmt.Errorf("report %s: use a .xml extension for JUnit XML or .md for Markdown", path)
+	}
+}
+
+// CheckPath returns an error if a report cannot be written in the format
+// of path.
+func CheckPath(path string) error {
+	_, err := writerFor(path)
+	return err
+}
+
+// WriteFile writes the report to path, as JUnit XML or Markdown depending
+// on its extension.
+func WriteFile(path string, r Report) error {
+	write, err := writerFor(path)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := write(&b, r); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
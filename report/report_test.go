@@ -0,0 +1,130 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// failedCreate is a snapshot of a stack whose creation failed and was
+// rolled back, with events newest first.
+func failedCreate() *fetcher.Snapshot {
+	stackEvent := func(after time.Duration, s types.ResourceStatus) fetcher.StackEvent {
+		return fetcher.StackEvent{StackID: "id", PhysicalID: "id", Resource: "stack", Status: s, Timestamp: start.Add(after)}
+	}
+	resourceEvent := func(after time.Duration, name, typ string, s types.ResourceStatus, reason string) fetcher.StackEvent {
+		return fetcher.StackEvent{StackID: "id", Resource: name, ResourceType: typ, Status: s, Reason: reason, Timestamp: start.Add(after)}
+	}
+	history := []fetcher.StackEvent{
+		stackEvent(0, types.ResourceStatusCreateInProgress),
+		resourceEvent(time.Second, "Bucket", "AWS::S3::Bucket", types.ResourceStatusCreateInProgress, ""),
+		resourceEvent(time.Second, "Queue", "AWS::SQS::Queue", types.ResourceStatusCreateInProgress, ""),
+		resourceEvent(5*time.Second, "Queue", "AWS::SQS::Queue", types.ResourceStatusCreateComplete, ""),
+		resourceEvent(31*time.Second, "Bucket", "AWS::S3::Bucket", types.ResourceStatusCreateFailed, "bucket already exists"),
+		stackEvent(32*time.Second, types.ResourceStatusRollbackInProgress),
+		resourceEvent(33*time.Second, "Queue", "AWS::SQS::Queue", types.ResourceStatusDeleteComplete, ""),
+		stackEvent(40*time.Second, types.ResourceStatus(types.StackStatusRollbackComplete)),
+	}
+	events := make([]fetcher.StackEvent, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		events = append(events, history[i])
+	}
+
+	return &fetcher.Snapshot{
+		Time: start.Add(time.Minute),
+		Stack: fetcher.Stack{
+			Name:    "my-stack",
+			ID:      "id",
+			Status:  types.StackStatusRollbackComplete,
+			Created: start,
+			Outputs: []fetcher.Output{{Key: "Url", Value: "https://example.com", Description: "where | it is"}},
+		},
+		Resources: []fetcher.StackResource{
+			{Resource: "Bucket", Type: "AWS::S3::Bucket", Status: types.ResourceStatusDeleteComplete},
+		},
+		Events: events,
+	}
+}
+
+func TestNew(t *testing.T) {
+	is := is.New(t)
+
+	r := New(failedCreate())
+	is.Equal(r.Stack, "my-stack")
+	is.Equal(r.Duration(), 40*time.Second)
+	is.True(!r.Succeeded())
+
+	// resources deleted during the operation are still reported
+	is.Equal(len(r.Resources), 2)
+	is.Equal(r.Resources[0].Name, "Bucket")
+	is.Equal(r.Resources[0].Duration, 30*time.Second)
+	is.Equal(r.Resources[1].Name, "Queue")
+	is.Equal(r.Resources[1].Type, "AWS::SQS::Queue")
+
+	failures := r.Failures()
+	is.Equal(len(failures), 1)
+	is.Equal(failures[0].Failure.Reason, "bucket already exists")
+
+	slow := r.Slowest(1)
+	is.Equal(len(slow), 1)
+	is.Equal(slow[0].Name, "Bucket")
+}
+
+func TestWriteJUnit(t *testing.T) {
+	is := is.New(t)
+
+	var b bytes.Buffer
+	is.NoErr(WriteJUnit(&b, New(failedCreate())))
+
+	var doc junitSuites
+	is.NoErr(xml.Unmarshal(b.Bytes(), &doc))
+	is.Equal(doc.Tests, 2)
+	is.Equal(doc.Failures, 1)
+	suite := doc.Suites[0]
+	is.Equal(suite.Name, "my-stack")
+	is.Equal(suite.Time, "40.000")
+	is.Equal(suite.Cases[0].Name, "Bucket")
+	is.Equal(suite.Cases[0].Classname, "AWS::S3::Bucket")
+	is.Equal(suite.Cases[0].Failure.Message, "bucket already exists")
+	is.Equal(suite.Cases[0].Failure.Type, "CREATE_FAILED")
+	is.True(suite.Cases[1].Failure == nil)
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	is := is.New(t)
+
+	var b bytes.Buffer
+	is.NoErr(WriteMarkdown(&b, New(failedCreate())))
+	out := b.String()
+
+	is.True(strings.Contains(out, "## Deployment of `my-stack` failed"))
+	is.True(strings.Contains(out, "| Duration | 40s |"))
+	is.True(strings.Contains(out, "- **Bucket** (`AWS::S3::Bucket`) `CREATE_FAILED`: bucket already exists"))
+	is.True(strings.Contains(out, "| Bucket | `AWS::S3::Bucket` | 30s |"))
+	is.True(strings.Contains(out, "| Url | `https://example.com` | where \\| it is |"))
+}
+
+func TestWriteFile(t *testing.T) {
+	is := is.New(t)
+
+	dir := t.TempDir()
+	r := New(failedCreate())
+	is.NoErr(WriteFile(filepath.Join(dir, "report.xml"), r))
+	is.NoErr(WriteFile(filepath.Join(dir, "summary.md"), r))
+
+	b, err := os.ReadFile(filepath.Join(dir, "report.xml"))
+	is.NoErr(err)
+	is.True(strings.HasPrefix(string(b), "<?xml"))
+
+	is.True(CheckPath("report.txt") != nil)
+}
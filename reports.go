@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/report"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// reporter writes the --report files whenever an operation on the stack is
+// seen to finish.
+type reporter struct {
+	paths []string
+
+	mu   sync.Mutex
+	prev *fetcher.Snapshot
+	// written is set once the reports for the latest operation have been
+	// written.
+	written bool
+}
+
+func newReporter(paths []string) (*reporter, error) {
+	for _, p := range paths {
+		if err := report.CheckPath(p); err != nil {
+			return nil, err
+		}
+	}
+	return &reporter{paths: paths}, nil
+}
+
+// Observe writes the reports if the operation finished since the previous
+// snapshot.
+func (r *reporter) Observe(snap *fetcher.Snapshot) {
+	if len(r.paths) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active := status.Stack(snap.Stack.Status).Active()
+	if active {
+		r.written = false
+	} else if r.prev != nil && status.Stack(r.prev.Stack.Status).Active() {
+		r.write(snap)
+	}
+	r.prev = snap
+}
+
+// Finish writes the reports for the final state of the stack, unless they
+// have already been written for its latest operation.
+func (r *reporter) Finish(snap *fetcher.Snapshot) {
+	if len(r.paths) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.written {
+		r.write(snap)
+	}
+}
+
+func (r *reporter) write(snap *fetcher.Snapshot) {
+	rep := report.New(snap)
+	for _, p := range r.paths {
+		if err := report.WriteFile(p, rep); err != nil {
+			log.Warn().Err(err).Msg("could not write report")
+			continue
+		}
+		log.Info().Str("path", p).Msg("wrote report")
+	}
+	r.written = true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/runner"
+)
+
+// outputLines is how much of a wrapped command's output the UI keeps.
+const outputLines = 1000
+
+type runCommand struct {
+	Stack string `long:"stack" required:"yes" description:"Name of the stack the command deploys"`
+}
+
+// run starts a deploy command and watches the stack as soon as it appears,
+// until the command exits, and returns its exit code. In plain mode the
+// command's output is printed as it arrives, between the stack's events;
+// otherwise it is shown in the UI and printed once the terminal has been
+// restored.
+func (a *app) run(ctx context.Context, args []string) (int, error) {
+	procCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var proc *runner.Process
+	var err error
+	if a.plain {
+		proc, err = runner.Stream(procCtx, args[0], args[1:], os.Stdout)
+	} else {
+		proc, err = runner.Start(procCtx, args[0], args[1:], outputLines)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	a.waitForStack = true
+	err = a.display(procCtx, proc)
+	// quitting early stops the command
+	cancel()
+	<-proc.Done()
+
+	// the command may exit before the final state of the stack is polled.
+	// display has stopped polling by now, so the fetcher is free to use
+	if snap, serr := a.fetcher.Snapshot(ctx); serr == nil {
+		a.reports.Finish(snap)
+	} else {
+		log.Warn().Err(serr).Msg("could not fetch the stack for the report")
+	}
+
+	for _, line := range proc.Lines() {
+		fmt.Println(line)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return proc.ExitCode(), nil
+}
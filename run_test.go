@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/metrics"
+)
+
+// fakeStack is a stack which does not exist until it has been polled once,
+// then is updated.
+type fakeStack struct {
+	mu    sync.Mutex
+	polls int
+}
+
+func (f *fakeStack) Snapshot(ctx context.Context) (*fetcher.Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.polls++
+	snap := &fetcher.Snapshot{Time: time.Now(), Stack: fetcher.Stack{Name: "stack", ID: "stack-id"}}
+	switch f.polls {
+	case 1:
+		return nil, fetcher.ErrStackNotFound
+	case 2:
+		snap.Stack.Status = types.StackStatusUpdateInProgress
+	default:
+		snap.Stack.Status = types.StackStatusUpdateComplete
+	}
+	return snap, nil
+}
+
+func (f *fakeStack) StackEvents(ctx context.Context, stack string) ([]fetcher.StackEvent, error) {
+	return nil, nil
+}
+
+func (f *fakeStack) Polls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.polls
+}
+
+func TestRunWaitsForStackAndPassesExitCode(t *testing.T) {
+	is := is.New(t)
+	t.Setenv("GITHUB_ACTIONS", "")
+
+	stack := &fakeStack{}
+	a := &app{
+		opts:        &options{},
+		stackName:   "stack",
+		fetcher:     stack,
+		metrics:     metrics.New(),
+		reports:     &reporter{},
+		plain:       true,
+		stackEvents: stack,
+		polling:     newPollControl(10 * time.Millisecond),
+	}
+
+	code, err := a.run(context.Background(), []string{"sh", "-c", "sleep 1; exit 3"})
+	is.NoErr(err)
+	is.Equal(code, 3)
+	is.True(stack.Polls() > 2) // kept polling once the stack was not found
+}
@@ -0,0 +1,157 @@
+// Package runner runs a command alongside the monitor, keeping the most
+// recent lines of its output or passing them straight on.
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ansi matches the colour and cursor escape sequences that deploy tools
+// write, which would corrupt the screen.
+var ansi = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// Process is a running command.
+type Process struct {
+	cmd      *exec.Cmd
+	maxLines int
+	// out, if set, is written each line of output instead of keeping it.
+	out io.Writer
+
+	mu    sync.Mutex
+	lines []string
+
+	updates  chan struct{}
+	done     chan struct{}
+	exitCode int
+}
+
+// Start runs a command, with its standard output and error combined, and
+// keeps the last maxLines lines it writes. The command has no standard
+// input, as the terminal belongs to the UI, so anything it asks reads end
+// of file. The command is killed if ctx is cancelled.
+func Start(ctx context.Context, name string, args []string, maxLines int) (*Process, error) {
+	return start(ctx, name, args, maxLines, nil)
+}
+
+// Stream runs a command like Start, but writes each line of its output to
+// out as soon as it is read instead of keeping it, so that nothing is lost
+// from a long log.
+func Stream(ctx context.Context, name string, args []string, out io.Writer) (*Process, error) {
+	return start(ctx, name, args, 0, out)
+}
+
+func start(ctx context.Context, name string, args []string, maxLines int, out io.Writer) (*Process, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	r, w := io.Pipe()
+	cmd.Stdout = w
+	cmd.Stderr = w
+	// children which outlive the command may hold its output open
+	cmd.WaitDelay = time.Second
+
+	p := &Process{
+		cmd:      cmd,
+		maxLines: maxLines,
+		out:      out,
+		updates:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", name, err)
+	}
+
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		p.read(r)
+	}()
+	go func() {
+		err := cmd.Wait()
+		w.Close()
+		<-read
+
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			p.exitCode = 0
+		case errors.As(err, &exitErr) && exitErr.ExitCode() > 0:
+			p.exitCode = exitErr.ExitCode()
+		default:
+			// killed by a signal, or could not be waited for
+			p.exitCode = 1
+		}
+		close(p.done)
+	}()
+	return p, nil
+}
+
+func (p *Process) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := ansi.ReplaceAllString(scanner.Text(), "")
+		if p.out != nil {
+			fmt.Fprintln(p.out, line)
+			continue
+		}
+		p.mu.Lock()
+		p.lines = append(p.lines, line)
+		if len(p.lines) > p.maxLines {
+			p.lines = p.lines[len(p.lines)-p.maxLines:]
+		}
+		p.mu.Unlock()
+
+		select {
+		case p.updates <- struct{}{}:
+		default:
+		}
+	}
+	// keep draining so the command never blocks on a full pipe
+	_, _ = io.Copy(io.Discard, r)
+}
+
+// scanLines splits on carriage returns as well as newlines, as progress
+// output often redraws a line in place.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// Lines returns the most recent output, or nothing if it is streamed.
+func (p *Process) Lines() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string{}, p.lines...)
+}
+
+// Updates receives whenever more output is available.
+func (p *Process) Updates() <-chan struct{} {
+	return p.updates
+}
+
+// Done is closed once the command has exited and all its output read.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// ExitCode returns the command's exit status, once Done is closed.
+func (p *Process) ExitCode() int {
+	return p.exitCode
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func wait(t *testing.T, p *Process) {
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not finish")
+	}
+}
+
+func TestOutputAndExitCode(t *testing.T) {
+	is := is.New(t)
+
+	p, err := Start(context.Background(), "sh", []string{"-c", `echo one; echo two >&2; printf '\033[32mthree\033[0m\r\n'; exit 3`}, 10)
+	is.NoErr(err)
+	wait(t, p)
+
+	is.Equal(p.Lines(), []string{"one", "two", "three"})
+	is.Equal(p.ExitCode(), 3)
+}
+
+func TestKeepsLastLines(t *testing.T) {
+	is := is.New(t)
+
+	p, err := Start(context.Background(), "sh", []string{"-c", `for i in 1 2 3 4 5; do echo $i; done`}, 2)
+	is.NoErr(err)
+	wait(t, p)
+
+	is.Equal(p.Lines(), []string{"4", "5"})
+	is.Equal(p.ExitCode(), 0)
+}
+
+func TestStream(t *testing.T) {
+	is := is.New(t)
+
+	var out bytes.Buffer
+	p, err := Stream(context.Background(), "sh", []string{"-c", `for i in 1 2 3 4 5; do echo $i; done; printf '\033[32mdone\033[0m'; exit 2`}, &out)
+	is.NoErr(err)
+	wait(t, p)
+
+	is.Equal(strings.Split(out.String(), "\n"), []string{"1", "2", "3", "4", "5", "done", ""})
+	is.Equal(len(p.Lines()), 0)
+	is.Equal(p.ExitCode(), 2)
+}
+
+func TestProgressRedraws(t *testing.T) {
+	is := is.New(t)
+
+	p, err := Start(context.Background(), "sh", []string{"-c", `printf '10%%\r50%%\r100%%\n'`}, 10)
+	is.NoErr(err)
+	wait(t, p)
+
+	is.Equal(p.Lines(), []string{"10%", "50%", "100%"})
+}
+
+func TestCancelled(t *testing.T) {
+	is := is.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err := Start(ctx, "sleep", []string{"10"}, 10)
+	is.NoErr(err)
+	cancel()
+	wait(t, p)
+
+	is.Equal(p.ExitCode(), 1)
+}
+
+func TestMissingCommand(t *testing.T) {
+	is := is.New(t)
+
+	_, err := Start(context.Background(), "cflivestatus-no-such-command", nil, 10)
+	is.True(err != nil)
+}
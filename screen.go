@@ -0,0 +1,705 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/gdamore/tcell/v2"
+	"github.com/simonrw/cflivestatus/changes"
+	"github.com/simonrw/cflivestatus/estimate"
+	"github.com/simonrw/cflivestatus/failure"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/logbuffer"
+	"github.com/simonrw/cflivestatus/status"
+	"github.com/simonrw/cflivestatus/theme"
+)
+
+type view int
+
+const (
+	resourcesView view = iota
+	hooksView
+	graphView
+	detailView
+	historyView
+)
+
+type Screen struct {
+	s        *tcell.Screen
+	view     view
+	identity identity
+	display  displayOptions
+	theme    theme.Theme
+	changes  *changes.Tracker
+
+	flashUntil   time.Time
+	flashMessage string
+
+	// pausedAt is when polling was paused, and so the time the view is
+	// frozen at, or zero while polling.
+	pausedAt time.Time
+	// help, if set, lists the key bindings over everything else.
+	help []string
+
+	// prompt, if set, is drawn over everything else and takes every key
+	// press.
+	prompt *prompt
+
+	// output, if set, is shown in a pane beneath the resource table.
+	output []string
+	// logs holds recent log lines, shown in a pane when showLogs is set.
+	logs     *logbuffer.Buffer
+	showLogs bool
+
+	// paths maps logical IDs to the construct paths shown in their place.
+	paths map[string]string
+	// selected is the logical ID of the resource under the cursor, if any.
+	selected string
+	// rows holds the logical IDs in the order last drawn, for moving the
+	// cursor.
+	rows []string
+
+	history historyBrowser
+	// keys are the key bindings, for hints about what to press.
+	keys keymap
+	// estimator predicts how long resources in progress will take.
+	estimator *estimate.Estimator
+}
+
+func NewScreen(id identity, display displayOptions, t theme.Theme) (*Screen, error) {
+	s, err := tcell.NewScreen()
+	if err != nil {
+		return nil, fmt.Errorf("creating screen: %w", err)
+	}
+	if err := s.Init(); err != nil {
+		return nil, fmt.Errorf("initialising screen: %w", err)
+	}
+	s.SetStyle(t.Style(theme.Default))
+	s.Clear()
+
+	return &Screen{s: &s, identity: id, display: display, theme: t, changes: changes.NewTracker()}, nil
+}
+
+// SetKeys sets the key bindings that hints refer to.
+func (s *Screen) SetKeys(keys keymap) {
+	s.keys = keys
+}
+
+func (s *Screen) write(line int, style tcell.Style, format string, args ...interface{}) {
+	s.writeAt(line, 0, style, format, args...)
+}
+
+// writeAt writes text starting at column col, returning the column after it.
+func (s *Screen) writeAt(line, col int, style tcell.Style, format string, args ...interface{}) int {
+	for _, r := range fmt.Sprintf(format, args...) {
+		(*s.s).SetContent(col, line, r, nil, style)
+		col++
+	}
+	return col
+}
+
+// Close restores the terminal.
+func (s *Screen) Close() {
+	(*s.s).Fini()
+}
+
+func (s *Screen) show() {
+	(*s.s).Show()
+}
+
+func (s *Screen) PollEvent() tcell.Event {
+	return (*s.s).PollEvent()
+}
+
+func (s *Screen) Sync() {
+	(*s.s).Sync()
+}
+
+func (s *Screen) clear() {
+	(*s.s).Clear()
+}
+
+// sort interface
+type byName []fetcher.StackResource
+
+func (n byName) Len() int           { return len(n) }
+func (n byName) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n byName) Less(i, j int) bool { return pathKey(n[i].Resource) < pathKey(n[j].Resource) }
+
+// pathKey orders construct paths so that everything beneath a construct
+// sorts together, before any sibling whose name shares a prefix.
+func pathKey(name string) string {
+	return strings.ReplaceAll(name, "/", "\x00")
+}
+
+func longestResourceName(res []fetcher.StackResource) int {
+	if len(res) == 0 {
+		return 0
+	}
+	m := len(res[0].Resource)
+	for _, r := range res {
+		if len(r.Resource) > m {
+			m = len(r.Resource)
+		}
+	}
+	return m
+}
+
+// ToggleView switches between the resource table and another view.
+func (s *Screen) ToggleView(v view) {
+	if s.view == v {
+		s.view = resourcesView
+	} else {
+		s.view = v
+	}
+}
+
+// Observe records a newly polled snapshot, so that rows which changed since
+// the previous one can be highlighted.
+func (s *Screen) Observe(snap *fetcher.Snapshot) {
+	s.changes.Observe(snap)
+}
+
+// Highlighting returns whether any changed row is still fading, and so the
+// screen should be redrawn soon.
+func (s *Screen) Highlighting() bool {
+	return s.changes.Highlighting(time.Now())
+}
+
+func (s *Screen) Render(snap *fetcher.Snapshot) {
+	s.clear()
+	i := 0
+	now := s.now()
+	header := fmt.Sprintf("%s  %s", now.Format(time.RFC1123Z), s.identity)
+	if eta := s.operationETA(snap, now); eta != "" {
+		header += "  " + eta
+	}
+	col := 0
+	if s.Paused() {
+		col = s.writeAt(i, 0, s.theme.Style(theme.Default).Reverse(true).Bold(true), " PAUSED ")
+		col = s.writeAt(i, col, s.theme.Style(theme.Default), " ")
+	}
+	if time.Now().Before(s.flashUntil) {
+		s.writeAt(i, col, s.theme.Style(theme.Default).Reverse(true), "%s  %s", header, s.flashMessage)
+	} else {
+		s.writeAt(i, col, s.theme.Style(theme.Default), "%s", header)
+	}
+	i++
+
+	if snap == nil {
+		s.write(i, s.theme.Style(theme.Default), "waiting for the stack to appear")
+		s.renderPanes()
+		s.show()
+		return
+	}
+
+	category := stackCategory(snap.Stack.Status)
+	if snap.Stack.Reason != "" {
+		s.write(i, s.theme.Style(category), "%s%s: %s (%s)", s.glyph(category), snap.Stack.Name, snap.Stack.Status, snap.Stack.Reason)
+	} else {
+		s.write(i, s.theme.Style(category), "%s%s: %s", s.glyph(category), snap.Stack.Name, snap.Stack.Status)
+	}
+	i++
+
+	if s.renderProgress(i, snap.Progress()) {
+		i++
+	}
+
+	switch s.view {
+	case hooksView:
+		s.renderHooks(i, snap.HookResults)
+	case graphView:
+		s.renderGraph(i, snap)
+	case detailView:
+		s.renderDetail(i, snap)
+	case historyView:
+		s.renderHistory(i)
+	default:
+		i = s.renderRootCauses(i, failure.Analyse(snap.Events))
+		s.renderResources(i, snap.Resources, s.changes)
+	}
+	s.renderPanes()
+	if s.prompt != nil {
+		s.renderPrompt(s.prompt)
+	}
+	if s.help != nil {
+		s.renderBox(append(s.help, "", "press any key to close"), -1)
+	}
+	s.show()
+}
+
+// SetOutput sets the lines shown in the output pane.
+func (s *Screen) SetOutput(lines []string) {
+	s.output = lines
+}
+
+// SetLogs sets where the log pane reads recent log lines from.
+func (s *Screen) SetLogs(logs *logbuffer.Buffer) {
+	s.logs = logs
+}
+
+// ToggleLogs shows or hides the log pane.
+func (s *Screen) ToggleLogs() {
+	s.showLogs = !s.showLogs
+}
+
+// ShowingLogs returns whether the log pane is shown.
+func (s *Screen) ShowingLogs() bool {
+	return s.showLogs
+}
+
+// outputHeight is the most lines shown at once in a pane.
+const outputHeight = 10
+
+// renderPanes draws the output and log panes along the bottom of the
+// screen, over anything else drawn there.
+func (s *Screen) renderPanes() {
+	_, bottom := (*s.s).Size()
+	if s.output != nil {
+		bottom = s.renderPane("output", s.output, bottom)
+	}
+	if s.showLogs && s.logs != nil {
+		s.renderPane("log", s.logs.Lines(), bottom)
+	}
+}
+
+// renderPane draws the most recent lines in a titled pane which ends above
+// line bottom, returning its top line.
+func (s *Screen) renderPane(title string, lines []string, bottom int) int {
+	width, height := (*s.s).Size()
+	rows := outputHeight
+	if rows > height/3 {
+		rows = height / 3
+	}
+	if len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+
+	top := bottom - rows - 1
+	style := s.theme.Style(theme.Default)
+	title = "── " + title + " "
+	s.writeAt(top, 0, style.Dim(true), "%s%s", title, strings.Repeat("─", max(width-len([]rune(title)), 0)))
+	for n := 0; n < rows; n++ {
+		line := ""
+		if n < len(lines) {
+			line = lines[n]
+		}
+		s.writeAt(top+1+n, 0, style, "%-*s", width, line)
+	}
+	return top
+}
+
+// SetPaused freezes the view at the current time, or unfreezes it.
+func (s *Screen) SetPaused(paused bool) {
+	if paused {
+		s.pausedAt = time.Now()
+	} else {
+		s.pausedAt = time.Time{}
+	}
+}
+
+// Paused returns whether the view is frozen.
+func (s *Screen) Paused() bool {
+	return !s.pausedAt.IsZero()
+}
+
+// now is the time the view is drawn at, which stands still while paused.
+func (s *Screen) now() time.Time {
+	if s.Paused() {
+		return s.pausedAt
+	}
+	return time.Now()
+}
+
+// ToggleHelp shows the given key bindings, or hides them if they are shown.
+func (s *Screen) ToggleHelp(lines []string) {
+	if s.help != nil {
+		s.help = nil
+		return
+	}
+	s.help = lines
+}
+
+// ShowingHelp returns whether the key bindings are shown.
+func (s *Screen) ShowingHelp() bool {
+	return s.help != nil
+}
+
+// dismissesHelp returns whether a key press only closes the help. Any key
+// does, except ctrl-c which still quits.
+func dismissesHelp(s *Screen, ev *tcell.EventKey) bool {
+	return s.ShowingHelp() && ev.Key() != tcell.KeyCtrlC
+}
+
+// Flash shows a message in the header for flashDuration.
+func (s *Screen) Flash(message string) {
+	s.flashUntil = time.Now().Add(flashDuration)
+	s.flashMessage = message
+}
+
+// renderResources draws the resource table, marking the changes seen by
+// tracker.
+func (s *Screen) renderResources(i int, statuses []fetcher.StackResource, tracker *changes.Tracker) {
+	now := s.now()
+	// recently removed resources stay on screen for a while, marked as such
+	statuses = append(statuses, tracker.Removed()...)
+
+	// rows are labelled with their construct paths where they are known
+	logicalIDs := map[string]string{}
+	shown := make([]fetcher.StackResource, 0, len(statuses))
+	for _, r := range statuses {
+		name := s.name(r.Resource)
+		logicalIDs[name] = r.Resource
+		r.Resource = name
+		shown = append(shown, r)
+	}
+	sortResources(shown, s.display.sort)
+	widths := columnWidths(shown, s.display.columns, now)
+	nameLength := longestResourceName(shown)
+
+	s.rows = s.rows[:0]
+	group := ""
+	for _, r := range shown {
+		id := logicalIDs[r.Resource]
+		s.rows = append(s.rows, id)
+
+		// resources within the same top level construct are grouped under
+		// its name
+		if s.paths != nil && s.display.sort == "name" {
+			top, _, nested := strings.Cut(r.Resource, "/")
+			if nested && top != group {
+				s.write(i, s.theme.Style(theme.Default).Bold(true), "%s%s/", strings.Repeat(" ", markerWidth), top)
+				i++
+			}
+			group = top
+		}
+
+		category := resourceCategory(r.Status)
+		c, _ := tracker.Change(id)
+		suffix := lastChange(c, now)
+		if s.stuck(r, now) {
+			category = theme.Stuck
+			suffix += "  [possibly stuck]"
+		}
+		suffix += s.resourceETA(id, r, now)
+		style := s.changeStyle(s.theme.Style(category), c, now)
+		if id == s.selected {
+			style = style.Underline(true)
+		}
+		s.write(i, style, "%s%s%s%s", s.marker(c, now), s.glyph(category), formatRow(r, s.display.columns, widths, now), suffix)
+		i++
+
+		// hook invocations are shown indented beneath their resource
+		for _, h := range r.Hooks {
+			category := hookCategory(h.Status)
+			fs := fmt.Sprintf("%%%ds  %%shook %%s %%s [%%s]", markerWidth+nameLength+len([]rune(s.glyph(category))))
+			if h.Reason != "" {
+				fs += " (%s)"
+				s.write(i, s.theme.Style(category), fs, "", s.glyph(category), h.Type, h.Status, h.FailureMode, h.Reason)
+			} else {
+				s.write(i, s.theme.Style(category), fs, "", s.glyph(category), h.Type, h.Status, h.FailureMode)
+			}
+			i++
+		}
+	}
+}
+
+func (s *Screen) renderHooks(i int, results []fetcher.HookResult) {
+	s.write(i, s.theme.Style(theme.Default), "Hooks%s", hints(s.keys.hint("return to resources", actionToggleHooks)))
+	i++
+	if len(results) == 0 {
+		s.write(i, s.theme.Style(theme.Default), "no hook invocations")
+		return
+	}
+
+	typeLength := 0
+	for _, h := range results {
+		if len(h.Type) > typeLength {
+			typeLength = len(h.Type)
+		}
+	}
+	for _, h := range results {
+		category := hookCategory(h.Status)
+		fs := fmt.Sprintf("%%s%%%ds: %%s %%s [%%s]", typeLength)
+		if h.Reason != "" {
+			fs += " (%s)"
+			s.write(i, s.theme.Style(category), fs, s.glyph(category), h.Type, h.Status, h.InvocationPoint, h.FailureMode, h.Reason)
+		} else {
+			s.write(i, s.theme.Style(category), fs, s.glyph(category), h.Type, h.Status, h.InvocationPoint, h.FailureMode)
+		}
+		i++
+	}
+}
+
+// renderGraph draws the template's dependency graph as a tree, starting
+// from the resources which depend on nothing, with each resource's
+// dependents beneath it. Resources reached along more than one path are
+// only expanded the first time.
+func (s *Screen) renderGraph(i int, snap *fetcher.Snapshot) {
+	s.write(i, s.theme.Style(theme.Default), "Dependencies%s", hints(s.keys.hint("return to resources", actionToggleGraph)))
+	i++
+	t := snap.Template
+	if t == nil {
+		s.write(i, s.theme.Style(theme.Default), "template not available")
+		return
+	}
+
+	statuses := map[string]types.ResourceStatus{}
+	for _, r := range snap.Resources {
+		statuses[r.Resource] = r.Status
+	}
+
+	expanded := map[string]bool{}
+	var draw func(name, prefix, branch string)
+	draw = func(name, prefix, branch string) {
+		st := statuses[name]
+		category := resourceCategory(st)
+		if st == "" {
+			st = "NOT_CREATED"
+			category = theme.Skipped
+		}
+		if expanded[name] {
+			s.write(i, s.theme.Style(category), "%s%s%s%s: %s (see above)", prefix, branch, s.glyph(category), name, st)
+			i++
+			return
+		}
+		expanded[name] = true
+		s.write(i, s.theme.Style(category), "%s%s%s%s: %s", prefix, branch, s.glyph(category), name, st)
+		i++
+
+		switch branch {
+		case "├─ ":
+			prefix += "│  "
+		case "└─ ":
+			prefix += "   "
+		}
+		children := t.Dependents(name)
+		for n, child := range children {
+			if n == len(children)-1 {
+				draw(child, prefix, "└─ ")
+			} else {
+				draw(child, prefix, "├─ ")
+			}
+		}
+	}
+
+	for _, root := range t.Roots() {
+		draw(root, "", "")
+	}
+	// a dependency cycle has no root, so make sure everything is shown
+	for _, name := range t.Names() {
+		if !expanded[name] {
+			draw(name, "", "")
+		}
+	}
+}
+
+// renderRootCauses pins the failures which caused the operation to fail
+// above the resource table, with their full reasons and the resources
+// cancelled because of them. It returns the next free line.
+func (s *Screen) renderRootCauses(i int, causes []failure.RootCause) int {
+	style := s.theme.Style(theme.Failed)
+	for _, c := range causes {
+		e := c.Event
+		s.write(i, style.Bold(true), "%sRoot cause: %s (%s) %s at %s", s.glyph(theme.Failed), e.Resource, e.ResourceType, e.Status, e.Timestamp.Local().Format("15:04:05"))
+		i = s.writeWrapped(i+1, 4, style, e.Reason)
+		if len(c.Cancelled) > 0 {
+			names := make([]string, 0, len(c.Cancelled))
+			for _, r := range c.Cancelled {
+				names = append(names, r.Resource)
+			}
+			i = s.writeWrapped(i, 4, s.theme.Style(theme.Default), fmt.Sprintf("%d cancelled as a result: %s", len(names), strings.Join(names, ", ")))
+		}
+	}
+	if len(causes) > 0 {
+		i++
+	}
+	return i
+}
+
+// writeWrapped writes text over as many lines as it needs, each indented,
+// returning the line after it.
+func (s *Screen) writeWrapped(line, indent int, style tcell.Style, text string) int {
+	width, _ := (*s.s).Size()
+	width -= indent
+	if width < 20 {
+		width = 20
+	}
+	var current []string
+	length := 0
+	flush := func() {
+		s.writeAt(line, indent, style, "%s", strings.Join(current, " "))
+		line++
+		current, length = nil, 0
+	}
+	for _, word := range strings.Fields(text) {
+		if length > 0 && length+1+len([]rune(word)) > width {
+			flush()
+		}
+		if length > 0 {
+			length++
+		}
+		current = append(current, word)
+		length += len([]rune(word))
+	}
+	if len(current) > 0 {
+		flush()
+	}
+	return line
+}
+
+// progressWidth is the width of the progress bar in the header.
+const progressWidth = 30
+
+// progressOrder is the order categories fill the progress bar: settled
+// resources from the left, then those still changing, then those yet to
+// start.
+var progressOrder = []status.Category{
+	status.Success, status.Skipped, status.RolledBack, status.Failed,
+	status.InProgress, status.RollingBack, status.Pending, status.Unknown,
+}
+
+// renderProgress draws a bar showing how many resources have settled, with a
+// segment per category, followed by the counts. It returns false if there
+// is nothing to show.
+func (s *Screen) renderProgress(i int, p fetcher.Progress) bool {
+	if p.Total == 0 {
+		return false
+	}
+
+	col := s.writeAt(i, 0, s.theme.Style(theme.Default), "[")
+	done := 0
+	var counts []string
+	for _, c := range progressOrder {
+		n := p.Counts[c]
+		if n == 0 {
+			continue
+		}
+		counts = append(counts, fmt.Sprintf("%d %s", n, c))
+
+		fill := '█'
+		switch {
+		case c.Active():
+			fill = '▒'
+		case !c.Settled():
+			fill = '░'
+		}
+		cells := (done+n)*progressWidth/p.Total - done*progressWidth/p.Total
+		col = s.writeAt(i, col, s.theme.Style(themeCategories[c]), "%s", strings.Repeat(string(fill), cells))
+		done += n
+	}
+	s.writeAt(i, col, s.theme.Style(theme.Default), "] %d/%d resources complete (%s)", p.Done(), p.Total, strings.Join(counts, ", "))
+	return true
+}
+
+// markerWidth is the width of the marker column in front of every row.
+const markerWidth = 2
+
+// marker flags rows which recently appeared in or disappeared from the
+// stack.
+func (s *Screen) marker(c changes.Change, now time.Time) string {
+	if now.Sub(c.At) >= s.changes.Retain {
+		return "  "
+	}
+	switch c.Kind {
+	case changes.Added:
+		return "+ "
+	case changes.Removed:
+		return "- "
+	default:
+		return "  "
+	}
+}
+
+// changeStyle highlights rows which changed in the last few seconds, in
+// reverse video at first and then in bold as the highlight fades.
+func (s *Screen) changeStyle(style tcell.Style, c changes.Change, now time.Time) tcell.Style {
+	if c.Kind == changes.Removed {
+		style = style.Dim(true).StrikeThrough(true)
+	}
+	switch f := s.changes.Fade(c, now); {
+	case f > 0.5:
+		return style.Reverse(true)
+	case f > 0:
+		return style.Bold(true)
+	default:
+		return style
+	}
+}
+
+// stuck returns whether a resource has been in progress for longer than it
+// is expected to take.
+func (s *Screen) stuck(r fetcher.StackResource, now time.Time) bool {
+	return s.display.stuckAfter > 0 && status.Resource(r.Status).Active() &&
+		!r.Timestamp.IsZero() && now.Sub(r.Timestamp) >= s.display.stuckAfter
+}
+
+// lastChange describes how long ago a row last changed.
+func lastChange(c changes.Change, now time.Time) string {
+	if c.At.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("  [%s ago]", formatAge(now.Sub(c.At)))
+}
+
+// formatAge formats a duration compactly, e.g. 12s, 4m12s or 2h05m.
+func formatAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Truncate(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// glyph returns the theme's marker for a category, followed by a space, or
+// nothing if the theme has no glyphs.
+func (s *Screen) glyph(c theme.Category) string {
+	g := s.theme.Glyph(c)
+	if g == "" {
+		return ""
+	}
+	return g + " "
+}
+
+func hookCategory(status types.HookStatus) theme.Category {
+	switch status {
+	case types.HookStatusHookCompleteSucceeded:
+		return theme.OK
+	case types.HookStatusHookInProgress:
+		return theme.Updating
+	case types.HookStatusHookCompleteFailed, types.HookStatusHookFailed:
+		return theme.Failed
+	default:
+		return theme.Default
+	}
+}
+
+// themeCategories maps status categories to the theme style used to draw
+// them.
+var themeCategories = map[status.Category]theme.Category{
+	status.Unknown:     theme.Default,
+	status.Pending:     theme.Pending,
+	status.InProgress:  theme.Updating,
+	status.Success:     theme.OK,
+	status.Failed:      theme.Failed,
+	status.RollingBack: theme.RollingBack,
+	status.RolledBack:  theme.RolledBack,
+	status.Skipped:     theme.Skipped,
+}
+
+func resourceCategory(s types.ResourceStatus) theme.Category {
+	return themeCategories[status.Resource(s)]
+}
+
+func stackCategory(s types.StackStatus) theme.Category {
+	return themeCategories[status.Stack(s)]
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/server"
+)
+
+type serveCommand struct {
+	Addr string `long:"addr" description:"Address to serve the dashboard on" default:":8080"`
+	Args struct {
+		Name string `required:"yes" positional-arg-name:"stack-name"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// serve polls the stack and serves the web dashboard until a fatal error
+// occurs.
+func (a *app) serve(ctx context.Context) error {
+	dispatcher, err := newDispatcher(a.opts.Webhooks, a.opts.WebhookTemplate, a.opts.NotifyOn, a.opts.StuckAfter)
+	if err != nil {
+		return fmt.Errorf("invalid notification configuration: %w", err)
+	}
+
+	srv := server.New()
+	go a.poll(ctx, dispatcher, func(snap *fetcher.Snapshot) {
+		srv.Observe(snap)
+	})
+
+	log.Info().Str("addr", a.opts.Serve.Addr).Msg("serving dashboard")
+	if err := http.ListenAndServe(a.opts.Serve.Addr, srv); err != nil {
+		return fmt.Errorf("serving dashboard: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package server serves a live web dashboard for a stack, fed by the same
+// snapshots as the terminal UI.
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+//go:embed static
+var static embed.FS
+
+// keepAlive is how often an idle event stream is sent a comment, so that
+// proxies do not close it.
+const keepAlive = 15 * time.Second
+
+// Server broadcasts snapshots to any number of browsers, so that many
+// viewers share a single poll loop.
+type Server struct {
+	mu          sync.Mutex
+	latest      []byte
+	subscribers map[chan []byte]struct{}
+
+	mux *http.ServeMux
+}
+
+func New() *Server {
+	s := &Server{
+		subscribers: map[chan []byte]struct{}{},
+		mux:         http.NewServeMux(),
+	}
+
+	root, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	s.mux.Handle("/", http.FileServer(http.FS(root)))
+	s.mux.HandleFunc("/api/snapshot", s.handleSnapshot)
+	s.mux.HandleFunc("/api/events", s.handleEvents)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Observe records a new snapshot and pushes it to every connected browser.
+func (s *Server) Observe(snap *fetcher.Snapshot) {
+	b, err := json.Marshal(newSnapshotView(snap))
+	if err != nil {
+		log.Warn().Err(err).Msg("error encoding snapshot")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latest = b
+	for ch := range s.subscribers {
+		// subscribers only care about the latest state, so replace anything
+		// they have not yet consumed
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- b
+	}
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	latest := s.latest
+	s.mu.Unlock()
+
+	if latest == nil {
+		http.Error(w, "no snapshot available yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(latest)
+}
+
+func (s *Server) subscribe() chan []byte {
+	ch := make(chan []byte, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest != nil {
+		ch <- s.latest
+	}
+	s.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (s *Server) unsubscribe(ch chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscribers, ch)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case b := <-ch:
+			if _, err := fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", b); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+)
+
+func snapshot(status types.StackStatus) *fetcher.Snapshot {
+	return &fetcher.Snapshot{
+		Time: time.Now(),
+		Stack: fetcher.Stack{
+			Name:   "stack",
+			Status: status,
+		},
+		Resources: []fetcher.StackResource{
+			{Resource: "Bucket", Type: "AWS::S3::Bucket", Status: types.ResourceStatusCreateInProgress},
+		},
+		Events: []fetcher.StackEvent{
+			{Resource: "Bucket", Status: types.ResourceStatusCreateInProgress},
+			{Resource: "Bucket", Hook: &fetcher.HookInvocation{Type: "Org::Hook"}},
+		},
+	}
+}
+
+func TestIndex(t *testing.T) {
+	is := is.New(t)
+
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/")
+	is.NoErr(err)
+	defer res.Body.Close()
+	is.Equal(res.StatusCode, http.StatusOK)
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	is.True(strings.Contains(string(body), "EventSource"))
+}
+
+func TestSnapshot(t *testing.T) {
+	is := is.New(t)
+
+	s := New()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/snapshot")
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(res.StatusCode, http.StatusServiceUnavailable)
+
+	s.Observe(snapshot(types.StackStatusCreateInProgress))
+
+	res, err = http.Get(srv.URL + "/api/snapshot")
+	is.NoErr(err)
+	defer res.Body.Close()
+	is.Equal(res.StatusCode, http.StatusOK)
+
+	var view snapshotView
+	is.NoErr(json.NewDecoder(res.Body).Decode(&view))
+	is.Equal(view.Stack.Status, "CREATE_IN_PROGRESS")
+	is.Equal(view.Stack.Category, "in-progress")
+	is.Equal(len(view.Resources), 1)
+	is.Equal(view.Resources[0].Type, "AWS::S3::Bucket")
+	is.Equal(len(view.Events), 1) // hook events are skipped
+}
+
+func TestEvents(t *testing.T) {
+	is := is.New(t)
+
+	s := New()
+	s.Observe(snapshot(types.StackStatusCreateInProgress))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/events", nil)
+	is.NoErr(err)
+	res, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer res.Body.Close()
+	is.Equal(res.Header.Get("Content-Type"), "text/event-stream")
+
+	statuses := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var view snapshotView
+			if err := json.Unmarshal([]byte(data), &view); err != nil {
+				continue
+			}
+			statuses <- view.Stack.Status
+		}
+	}()
+
+	// the current snapshot is sent on connection, then any updates
+	is.Equal(<-statuses, "CREATE_IN_PROGRESS")
+	s.Observe(snapshot(types.StackStatusCreateComplete))
+	is.Equal(<-statuses, "CREATE_COMPLETE")
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"time"
+
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/status"
+)
+
+// snapshotView is the JSON representation of a snapshot served to browsers.
+type snapshotView struct {
+	Time      time.Time      `json:"time"`
+	Stack     stackView      `json:"stack"`
+	Resources []resourceView `json:"resources"`
+	Events    []eventView    `json:"events"`
+}
+
+type stackView struct {
+	Name        string    `json:"name"`
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	Category    string    `json:"category"`
+	Reason      string    `json:"reason,omitempty"`
+	Created     time.Time `json:"created"`
+	LastUpdated time.Time `json:"last_updated,omitzero"`
+}
+
+type resourceView struct {
+	Resource   string    `json:"resource"`
+	Type       string    `json:"type"`
+	PhysicalID string    `json:"physical_id,omitempty"`
+	Status     string    `json:"status"`
+	Category   string    `json:"category"`
+	Reason     string    `json:"reason,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+type eventView struct {
+	ID        string    `json:"id"`
+	Resource  string    `json:"resource"`
+	Type      string    `json:"type"`
+	Status    string    `json:"status"`
+	Category  string    `json:"category"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func newSnapshotView(snap *fetcher.Snapshot) snapshotView {
+	out := snapshotView{
+		Time: snap.Time,
+		Stack: stackView{
+			Name:        snap.Stack.Name,
+			ID:          snap.Stack.ID,
+			Status:      string(snap.Stack.Status),
+			Category:    string(status.Stack(snap.Stack.Status)),
+			Reason:      snap.Stack.Reason,
+			Created:     snap.Stack.Created,
+			LastUpdated: snap.Stack.LastUpdated,
+		},
+		Resources: []resourceView{},
+		Events:    []eventView{},
+	}
+	for _, r := range snap.Resources {
+		out.Resources = append(out.Resources, resourceView{
+			Resource:   r.Resource,
+			Type:       r.Type,
+			PhysicalID: r.PhysicalID,
+			Status:     string(r.Status),
+			Category:   string(status.Resource(r.Status)),
+			Reason:     r.Reason,
+			Timestamp:  r.Timestamp,
+		})
+	}
+	for _, e := range snap.Events {
+		// hook invocations carry no resource status of their own
+		if e.Hook != nil {
+			continue
+		}
+		out.Events = append(out.Events, eventView{
+			ID:        e.ID,
+			Resource:  e.Resource,
+			Type:      e.ResourceType,
+			Status:    string(e.Status),
+			Category:  string(status.Resource(e.Status)),
+			Reason:    e.Reason,
+			Timestamp: e.Timestamp,
+		})
+	}
+	return out
+}
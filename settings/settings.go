@@ -0,0 +1,175 @@
+// Package settings reads the cflivestatus configuration file.
+//
+// The file is made up of sections in the style of the AWS config file. The
+// [default] section always applies, and a [profile NAME] section is layered
+// on top of it when that profile is selected. Each line sets an option by
+// its long command line name:
+//
+//	[default]
+//	sleep-time = 5s
+//	webhook = https://example.com/hook
+//
+//	[profile prod]
+//	region = us-east-1
+//	colour.failed = maroon
+//
+// A [theme NAME] section defines a colour theme, whose keys are style
+// categories rather than options:
+//
+//	[theme mine]
+//	base = monochrome
+//	failed = white on red bold
+//	glyph.failed = !
+package settings
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultProfile is the section which is always applied.
+const DefaultProfile = "default"
+
+// Setting is a single key and value from the file.
+type Setting struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// File is a parsed configuration file.
+type File struct {
+	Path     string
+	profiles map[string][]Setting
+	themes   map[string][]Setting
+}
+
+// DefaultPath returns the location of the configuration file under the XDG
+// config directory.
+func DefaultPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "cflivestatus", "config")
+}
+
+// Load reads the file at path. A missing file is only an error if required
+// is set, otherwise it is treated as empty.
+func Load(path string, required bool) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return &File{Path: path, profiles: map[string][]Setting{}, themes: map[string][]Setting{}}, nil
+		}
+		return nil, fmt.Errorf("opening config file: %w", err)
+	}
+	defer f.Close()
+
+	file, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	file.Path = path
+	return file, nil
+}
+
+// Parse reads a configuration file.
+func Parse(r io.Reader) (*File, error) {
+	file := &File{profiles: map[string][]Setting{}, themes: map[string][]Setting{}}
+
+	var section map[string][]Setting
+	name := ""
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", line)
+			}
+			kind, n, err := parseSection(strings.TrimSpace(text[1 : len(text)-1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			section, name = file.profiles, n
+			if kind == "theme" {
+				section = file.themes
+			}
+			if _, ok := section[name]; !ok {
+				section[name] = nil
+			}
+			continue
+		}
+
+		if section == nil {
+			return nil, fmt.Errorf("line %d: setting outside of a section", line)
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+		section[name] = append(section[name], Setting{
+			Key:   strings.TrimSpace(key),
+			Value: unquote(strings.TrimSpace(value)),
+			Line:  line,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	return file, nil
+}
+
+// parseSection returns the kind, profile or theme, and name of a section.
+func parseSection(header string) (string, string, error) {
+	if header == DefaultProfile {
+		return "profile", DefaultProfile, nil
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 2 || (fields[0] != "profile" && fields[0] != "theme") {
+		return "", "", fmt.Errorf("unknown section %q, expected [default], [profile NAME] or [theme NAME]", header)
+	}
+	return fields[0], fields[1], nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Profile returns the settings for the named profile, layered on top of the
+// default section. An empty name selects only the defaults.
+func (f *File) Profile(name string) ([]Setting, error) {
+	out := append([]Setting{}, f.profiles[DefaultProfile]...)
+	if name == "" || name == DefaultProfile {
+		return out, nil
+	}
+	settings, ok := f.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found in %s", name, f.Path)
+	}
+	return append(out, settings...), nil
+}
+
+// Theme returns the settings of the named theme section.
+func (f *File) Theme(name string) ([]Setting, bool) {
+	settings, ok := f.themes[name]
+	return settings, ok
+}
@@ -0,0 +1,108 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+const example = `
+# global defaults
+[default]
+sleep-time = 5s
+webhook = https://example.com/a
+
+[profile prod]
+region = "us-east-1"
+colour.failed = maroon
+
+[theme mine]
+base = monochrome
+glyph.failed = !
+`
+
+func TestParseProfiles(t *testing.T) {
+	is := is.New(t)
+
+	f, err := Parse(strings.NewReader(example))
+	is.NoErr(err)
+
+	settings, err := f.Profile("")
+	is.NoErr(err)
+	is.Equal(settings, []Setting{
+		{Key: "sleep-time", Value: "5s", Line: 4},
+		{Key: "webhook", Value: "https://example.com/a", Line: 5},
+	})
+
+	settings, err = f.Profile("prod")
+	is.NoErr(err)
+	is.Equal(len(settings), 4)
+	is.Equal(settings[2], Setting{Key: "region", Value: "us-east-1", Line: 8})
+	is.Equal(settings[3], Setting{Key: "colour.failed", Value: "maroon", Line: 9})
+
+	_, err = f.Profile("missing")
+	is.True(err != nil)
+}
+
+func TestParseThemes(t *testing.T) {
+	is := is.New(t)
+
+	f, err := Parse(strings.NewReader(example))
+	is.NoErr(err)
+
+	settings, ok := f.Theme("mine")
+	is.True(ok)
+	is.Equal(settings, []Setting{
+		{Key: "base", Value: "monochrome", Line: 12},
+		{Key: "glyph.failed", Value: "!", Line: 13},
+	})
+
+	_, ok = f.Theme("prod")
+	is.True(!ok)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+	}{
+		{"outside section", "region = x\n"},
+		{"bad section", "[staging]\n"},
+		{"unterminated section", "[default\n"},
+		{"missing value", "[default]\nregion\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := Parse(strings.NewReader(tc.input))
+			is.True(err != nil)
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "config")
+	f, err := Load(path, false)
+	is.NoErr(err)
+	settings, err := f.Profile("")
+	is.NoErr(err)
+	is.Equal(len(settings), 0)
+
+	_, err = Load(path, true)
+	is.True(err != nil)
+}
+
+func TestDefaultPath(t *testing.T) {
+	is := is.New(t)
+
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	is.Equal(DefaultPath(), "/xdg/cflivestatus/config")
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	home, err := os.UserHomeDir()
+	is.NoErr(err)
+	is.Equal(DefaultPath(), filepath.Join(home, ".config", "cflivestatus", "config"))
+}
@@ -0,0 +1,140 @@
+// Package status classifies CloudFormation resource and stack statuses, so
+// that every part of the program agrees on what a status means.
+package status
+
+import "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+
+// Category is a class of statuses which mean the same thing to the user.
+type Category string
+
+const (
+	Unknown     Category = "unknown"
+	Pending     Category = "pending"
+	InProgress  Category = "in-progress"
+	Success     Category = "success"
+	Failed      Category = "failed"
+	RollingBack Category = "rolling-back"
+	RolledBack  Category = "rolled-back"
+	Skipped     Category = "skipped"
+)
+
+// Categories lists every category, in a stable order.
+var Categories = []Category{Unknown, Pending, InProgress, Success, Failed, RollingBack, RolledBack, Skipped}
+
+// Active returns whether something in this category is still changing.
+func (c Category) Active() bool {
+	return c == InProgress || c == RollingBack
+}
+
+// Settled returns whether something in this category has stopped changing.
+func (c Category) Settled() bool {
+	return c == Success || c == Failed || c == RolledBack || c == Skipped
+}
+
+// ResourcePending is not a CloudFormation status. It marks resources from the
+// template which the current operation has not started yet.
+const ResourcePending types.ResourceStatus = "PENDING"
+
+var resources = map[types.ResourceStatus]Category{
+	ResourcePending:                              Pending,
+	types.ResourceStatusCreateInProgress:         InProgress,
+	types.ResourceStatusCreateFailed:             Failed,
+	types.ResourceStatusCreateComplete:           Success,
+	types.ResourceStatusDeleteInProgress:         InProgress,
+	types.ResourceStatusDeleteFailed:             Failed,
+	types.ResourceStatusDeleteComplete:           Success,
+	types.ResourceStatusDeleteSkipped:            Skipped,
+	types.ResourceStatusUpdateInProgress:         InProgress,
+	types.ResourceStatusUpdateFailed:             Failed,
+	types.ResourceStatusUpdateComplete:           Success,
+	types.ResourceStatusImportFailed:             Failed,
+	types.ResourceStatusImportComplete:           Success,
+	types.ResourceStatusImportInProgress:         InProgress,
+	types.ResourceStatusImportRollbackInProgress: RollingBack,
+	types.ResourceStatusImportRollbackFailed:     Failed,
+	types.ResourceStatusImportRollbackComplete:   RolledBack,
+	types.ResourceStatusExportFailed:             Failed,
+	types.ResourceStatusExportComplete:           Success,
+	types.ResourceStatusExportInProgress:         InProgress,
+	types.ResourceStatusExportRollbackInProgress: RollingBack,
+	types.ResourceStatusExportRollbackFailed:     Failed,
+	types.ResourceStatusExportRollbackComplete:   RolledBack,
+	types.ResourceStatusUpdateRollbackInProgress: RollingBack,
+	types.ResourceStatusUpdateRollbackComplete:   RolledBack,
+	types.ResourceStatusUpdateRollbackFailed:     Failed,
+	types.ResourceStatusRollbackInProgress:       RollingBack,
+	types.ResourceStatusRollbackComplete:         RolledBack,
+	types.ResourceStatusRollbackFailed:           Failed,
+}
+
+var stacks = map[types.StackStatus]Category{
+	types.StackStatusCreateInProgress:                        InProgress,
+	types.StackStatusCreateFailed:                            Failed,
+	types.StackStatusCreateComplete:                          Success,
+	types.StackStatusRollbackInProgress:                      RollingBack,
+	types.StackStatusRollbackFailed:                          Failed,
+	types.StackStatusRollbackComplete:                        RolledBack,
+	types.StackStatusDeleteInProgress:                        InProgress,
+	types.StackStatusDeleteFailed:                            Failed,
+	types.StackStatusDeleteComplete:                          Success,
+	types.StackStatusUpdateInProgress:                        InProgress,
+	types.StackStatusUpdateCompleteCleanupInProgress:         InProgress,
+	types.StackStatusUpdateComplete:                          Success,
+	types.StackStatusUpdateFailed:                            Failed,
+	types.StackStatusUpdateRollbackInProgress:                RollingBack,
+	types.StackStatusUpdateRollbackFailed:                    Failed,
+	types.StackStatusUpdateRollbackCompleteCleanupInProgress: RollingBack,
+	types.StackStatusUpdateRollbackComplete:                  RolledBack,
+	types.StackStatusReviewInProgress:                        Pending,
+	types.StackStatusImportInProgress:                        InProgress,
+	types.StackStatusImportComplete:                          Success,
+	types.StackStatusImportRollbackInProgress:                RollingBack,
+	types.StackStatusImportRollbackFailed:                    Failed,
+	types.StackStatusImportRollbackComplete:                  RolledBack,
+}
+
+// Resource classifies a resource status.
+func Resource(s types.ResourceStatus) Category {
+	if c, ok := resources[s]; ok {
+		return c
+	}
+	return Unknown
+}
+
+// Stack classifies a stack status.
+func Stack(s types.StackStatus) Category {
+	if c, ok := stacks[s]; ok {
+		return c
+	}
+	return Unknown
+}
+
+// Succeeding returns whether the current or most recent operation on a stack
+// in this status is, so far, going to plan.
+func Succeeding(s types.StackStatus) bool {
+	switch Stack(s) {
+	case Pending, InProgress, Success:
+		return true
+	default:
+		return false
+	}
+}
+
+// StartsOperation returns whether a stack entering this status has just
+// started to be created, updated, deleted or imported.
+func StartsOperation(s types.StackStatus) bool {
+	switch s {
+	case types.StackStatusCreateInProgress,
+		types.StackStatusUpdateInProgress,
+		types.StackStatusDeleteInProgress,
+		types.StackStatusImportInProgress:
+		return true
+	default:
+		return false
+	}
+}
+
+// Finished returns whether the stack is not part way through an operation.
+func Finished(s types.StackStatus) bool {
+	return !Stack(s).Active()
+}
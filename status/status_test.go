@@ -0,0 +1,64 @@
+package status
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+)
+
+func TestEveryResourceStatusClassified(t *testing.T) {
+	for _, s := range types.ResourceStatus("").Values() {
+		if Resource(s) == Unknown {
+			t.Errorf("resource status %s is not classified", s)
+		}
+	}
+}
+
+func TestEveryStackStatusClassified(t *testing.T) {
+	for _, s := range types.StackStatus("").Values() {
+		if Stack(s) == Unknown {
+			t.Errorf("stack status %s is not classified", s)
+		}
+	}
+}
+
+func TestResource(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Resource(types.ResourceStatusUpdateRollbackInProgress), RollingBack)
+	is.Equal(Resource(types.ResourceStatusUpdateRollbackComplete), RolledBack)
+	is.Equal(Resource(types.ResourceStatusDeleteSkipped), Skipped)
+	is.Equal(Resource(types.ResourceStatusImportInProgress), InProgress)
+	is.Equal(Resource(types.ResourceStatusExportFailed), Failed)
+	is.Equal(Resource("SOMETHING_NEW"), Unknown)
+}
+
+func TestStack(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Stack(types.StackStatusUpdateCompleteCleanupInProgress), InProgress)
+	is.True(Succeeding(types.StackStatusUpdateCompleteCleanupInProgress))
+	is.True(!Finished(types.StackStatusUpdateCompleteCleanupInProgress))
+
+	is.Equal(Stack(types.StackStatusUpdateRollbackCompleteCleanupInProgress), RollingBack)
+	is.True(!Succeeding(types.StackStatusUpdateRollbackCompleteCleanupInProgress))
+	is.True(!Finished(types.StackStatusUpdateRollbackCompleteCleanupInProgress))
+
+	is.Equal(Stack(types.StackStatusUpdateRollbackComplete), RolledBack)
+	is.True(!Succeeding(types.StackStatusUpdateRollbackComplete))
+	is.True(Finished(types.StackStatusUpdateRollbackComplete))
+
+	is.True(Succeeding(types.StackStatusCreateComplete))
+	is.True(Finished(types.StackStatusCreateComplete))
+}
+
+func TestStartsOperation(t *testing.T) {
+	is := is.New(t)
+
+	is.True(StartsOperation(types.StackStatusCreateInProgress))
+	is.True(StartsOperation(types.StackStatusImportInProgress))
+	is.True(!StartsOperation(types.StackStatusUpdateCompleteCleanupInProgress))
+	is.True(!StartsOperation(types.StackStatusUpdateRollbackInProgress))
+	is.True(!StartsOperation(types.StackStatusReviewInProgress))
+}
@@ -0,0 +1,196 @@
+// Package store keeps a record of the operations seen on each stack, as
+// JSON files under the XDG data directory, so that deployments can be
+// compared over time.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/operation"
+	"github.com/simonrw/cflivestatus/report"
+)
+
+// maxRecords is how many operations are kept for each stack.
+const maxRecords = 200
+
+// Record is a finished operation on a stack.
+type Record struct {
+	Stack     string               `json:"stack"`
+	StackID   string               `json:"stack_id"`
+	Token     string               `json:"token,omitempty"`
+	Kind      string               `json:"kind"`
+	Status    types.StackStatus    `json:"status"`
+	Start     time.Time            `json:"start"`
+	End       time.Time            `json:"end"`
+	Resources []Resource           `json:"resources"`
+	Events    []fetcher.StackEvent `json:"events"`
+}
+
+// Resource is how long a resource took during an operation.
+type Resource struct {
+	Name     string               `json:"name"`
+	Type     string               `json:"type"`
+	Status   types.ResourceStatus `json:"status"`
+	Duration time.Duration        `json:"duration"`
+}
+
+// Duration is how long the operation took.
+func (r Record) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// FromOperation records a finished operation. It returns false if the
+// operation has not finished, or its start is not known, as its duration
+// would be misleading.
+func FromOperation(op operation.Operation) (Record, bool) {
+	if !op.Finished() || op.Truncated {
+		return Record{}, false
+	}
+	rec := Record{
+		Stack:   op.StackName,
+		StackID: op.StackID,
+		Token:   op.Token,
+		Kind:    op.Kind(),
+		Status:  op.Status,
+		Start:   op.Start,
+		End:     op.End,
+		Events:  op.Events,
+	}
+	for _, r := range report.New(op.Snapshot()).Resources {
+		rec.Resources = append(rec.Resources, Resource{Name: r.Name, Type: r.Type, Status: r.Status, Duration: r.Duration})
+	}
+	return rec, true
+}
+
+// Store is a directory of records, with a file per stack.
+type Store struct {
+	dir string
+}
+
+// DefaultDir is where records are kept unless another directory is given:
+// $XDG_DATA_HOME/cflivestatus/history, or ~/.local/share/cflivestatus/history.
+func DefaultDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "cflivestatus", "history")
+}
+
+func Open(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// path is the file holding a stack's records. Stack names are limited to
+// letters, digits and hyphens, so are safe to use as file names.
+func (s *Store) path(stack string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(stack, string(filepath.Separator), "_")+".json")
+}
+
+// Load returns the records of a stack, oldest first.
+func (s *Store) Load(stack string) ([]Record, error) {
+	b, err := os.ReadFile(s.path(stack))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	var records []Record
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("parsing history %s: %w", s.path(stack), err)
+	}
+	return records, nil
+}
+
+// Save adds records to those of their stacks, replacing any of the same
+// operation, and returns how many were new.
+func (s *Store) Save(records ...Record) (int, error) {
+	byStack := map[string][]Record{}
+	for _, r := range records {
+		byStack[r.Stack] = append(byStack[r.Stack], r)
+	}
+
+	added := 0
+	for stack, recs := range byStack {
+		existing, err := s.Load(stack)
+		if err != nil {
+			return added, err
+		}
+		existing, n := Merge(existing, recs...)
+		added += n
+		if len(existing) > maxRecords {
+			existing = existing[len(existing)-maxRecords:]
+		}
+		if err := s.write(stack, existing); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// Merge adds more records to records, replacing any of the same
+// operation, and returns them oldest first along with how many were new.
+func Merge(records []Record, more ...Record) ([]Record, int) {
+	added := 0
+	for _, r := range more {
+		if i := find(records, r); i >= 0 {
+			records[i] = r
+			continue
+		}
+		records = append(records, r)
+		added++
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Start.Before(records[j].Start) })
+	return records, added
+}
+
+func find(records []Record, r Record) int {
+	for i, e := range records {
+		if e.StackID == r.StackID && e.Start.Equal(r.Start) {
+			return i
+		}
+	}
+	return -1
+}
+
+// write replaces a stack's file, through a temporary file so that it is
+// never left half written.
+func (s *Store) write(stack string, records []Record) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("creating history directory: %w", err)
+	}
+	b, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("encoding history: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".history-*")
+	if err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(stack)); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/matryer/is"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/operation"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func record(day int, durations map[string]time.Duration) Record {
+	r := Record{
+		Stack:   "app",
+		StackID: "stack-id",
+		Kind:    "UPDATE",
+		Status:  types.StackStatusUpdateComplete,
+		Start:   start.AddDate(0, 0, day),
+		End:     start.AddDate(0, 0, day).Add(5 * time.Minute),
+	}
+	for name, d := range durations {
+		r.Resources = append(r.Resources, Resource{Name: name, Type: "AWS::Lambda::Function", Duration: d})
+	}
+	return r
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	is := is.New(t)
+
+	s := Open(filepath.Join(t.TempDir(), "history"))
+	records, err := s.Load("app")
+	is.NoErr(err)
+	is.Equal(len(records), 0)
+
+	added, err := s.Save(record(2, nil), record(1, nil))
+	is.NoErr(err)
+	is.Equal(added, 2)
+
+	// saving the same operation again replaces it
+	again := record(1, map[string]time.Duration{"Function": time.Minute})
+	added, err = s.Save(again)
+	is.NoErr(err)
+	is.Equal(added, 0)
+
+	records, err = s.Load("app")
+	is.NoErr(err)
+	is.Equal(len(records), 2)
+	is.True(records[0].Start.Before(records[1].Start))
+	is.Equal(records[0].Resources[0].Duration, time.Minute)
+	is.Equal(records[0].Duration(), 5*time.Minute)
+}
+
+func TestDefaultDir(t *testing.T) {
+	is := is.New(t)
+
+	t.Setenv("XDG_DATA_HOME", "/xdg")
+	is.Equal(DefaultDir(), "/xdg/cflivestatus/history")
+
+	t.Setenv("XDG_DATA_HOME", "")
+	home, err := os.UserHomeDir()
+	is.NoErr(err)
+	is.Equal(DefaultDir(), filepath.Join(home, ".local", "share", "cflivestatus", "history"))
+}
+
+func TestFromOperation(t *testing.T) {
+	is := is.New(t)
+
+	stackEvent := func(after time.Duration, s types.StackStatus) fetcher.StackEvent {
+		return fetcher.StackEvent{StackID: "id", PhysicalID: "id", Resource: "app", Status: types.ResourceStatus(s), Timestamp: start.Add(after)}
+	}
+	// newest first
+	events := []fetcher.StackEvent{
+		stackEvent(time.Minute, types.StackStatusCreateComplete),
+		{StackID: "id", Resource: "Function", ResourceType: "AWS::Lambda::Function", Status: types.ResourceStatusCreateComplete, Timestamp: start.Add(40 * time.Second)},
+		{StackID: "id", Resource: "Function", ResourceType: "AWS::Lambda::Function", Status: types.ResourceStatusCreateInProgress, Timestamp: start.Add(10 * time.Second)},
+		stackEvent(0, types.StackStatusCreateInProgress),
+	}
+	ops := operation.Split(events)
+
+	rec, ok := FromOperation(ops[0])
+	is.True(ok)
+	is.Equal(rec.Kind, "CREATE")
+	is.Equal(rec.Duration(), time.Minute)
+	is.Equal(len(rec.Events), 4)
+	is.Equal(rec.Resources, []Resource{{Name: "Function", Type: "AWS::Lambda::Function", Status: types.ResourceStatusCreateComplete, Duration: 30 * time.Second}})
+
+	_, ok = FromOperation(operation.Split(events[1:])[0])
+	is.True(!ok)
+}
+
+func TestRegressions(t *testing.T) {
+	is := is.New(t)
+
+	records := []Record{
+		record(1, map[string]time.Duration{"Function": 30 * time.Second, "Role": 20 * time.Second}),
+		record(2, map[string]time.Duration{"Function": 25 * time.Second, "Role": 20 * time.Second}),
+		record(3, map[string]time.Duration{"Function": 35 * time.Second, "Role": 19 * time.Second}),
+		// the role is slower, but by too little to matter
+		record(4, map[string]time.Duration{"Function": 90 * time.Second, "Role": 25 * time.Second}),
+	}
+
+	regressions := Regressions(records, 2)
+	is.Equal(len(regressions), 1)
+	is.Equal(regressions[0].Resource, "Function")
+	is.Equal(regressions[0].Median, 30*time.Second)
+	is.Equal(regressions[0].Factor(), 3.0)
+
+	is.Equal(len(Regressions(records[:3], 2)), 0)
+}
+
+func TestMedian(t *testing.T) {
+	is := is.New(t)
+	is.Equal(Median(nil), time.Duration(0))
+	is.Equal(Median([]time.Duration{3, 1, 2}), time.Duration(2))
+	is.Equal(Median([]time.Duration{4, 1, 2, 3}), time.Duration(2))
+}
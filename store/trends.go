@@ -0,0 +1,83 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// minSamples is how many earlier durations a resource needs before it can
+// be said to have regressed.
+const minSamples = 3
+
+// minSlowdown ignores regressions of a few seconds, which are noise.
+const minSlowdown = 10 * time.Second
+
+// Regression is a resource which took much longer than it usually does.
+type Regression struct {
+	Resource string
+	Type     string
+	Duration time.Duration
+	Median   time.Duration
+}
+
+// Factor is how many times longer than usual the resource took.
+func (r Regression) Factor() float64 {
+	return float64(r.Duration) / float64(r.Median)
+}
+
+// Regressions compares the resources in the latest of records, which are
+// oldest first, with their median durations in the earlier ones. Those
+// which took at least factor times as long are returned, worst first.
+func Regressions(records []Record, factor float64) []Regression {
+	if len(records) < 2 {
+		return nil
+	}
+	latest := records[len(records)-1]
+	earlier := records[:len(records)-1]
+
+	var out []Regression
+	for _, r := range latest.Resources {
+		if r.Duration <= 0 {
+			continue
+		}
+		samples := ResourceDurations(earlier, r.Name)
+		if len(samples) < minSamples {
+			continue
+		}
+		median := Median(samples)
+		if median <= 0 || float64(r.Duration) < factor*float64(median) || r.Duration-median < minSlowdown {
+			continue
+		}
+		out = append(out, Regression{Resource: r.Name, Type: r.Type, Duration: r.Duration, Median: median})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Factor() > out[j].Factor() })
+	return out
+}
+
+// ResourceDurations returns how long a resource took in each record in
+// which it changed.
+func ResourceDurations(records []Record, name string) []time.Duration {
+	var out []time.Duration
+	for _, rec := range records {
+		for _, r := range rec.Resources {
+			if r.Name == name && r.Duration > 0 {
+				out = append(out, r.Duration)
+			}
+		}
+	}
+	return out
+}
+
+// Median returns the middle of the durations, or zero if there are none.
+func Median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,170 @@
+package template
+
+import (
+	"sort"
+	"strings"
+)
+
+// references returns the names referred to by Ref, Fn::GetAtt and Fn::Sub
+// anywhere within v.
+func references(v interface{}) []string {
+	var out []string
+	var walk func(v interface{}, locals map[string]bool)
+	walk = func(v interface{}, locals map[string]bool) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, e := range v {
+				walk(e, locals)
+			}
+		case map[string]interface{}:
+			if len(v) == 1 {
+				if ref, ok := v["Ref"].(string); ok {
+					if !locals[ref] {
+						out = append(out, ref)
+					}
+					return
+				}
+				if att, ok := v["Fn::GetAtt"]; ok {
+					if name := getAttResource(att); name != "" && !locals[name] {
+						out = append(out, name)
+					}
+					walk(att, locals)
+					return
+				}
+				if sub, ok := v["Fn::Sub"]; ok {
+					out = append(out, subReferences(sub, locals, walk)...)
+					return
+				}
+			}
+			for _, e := range v {
+				walk(e, locals)
+			}
+		}
+	}
+	walk(v, nil)
+	return out
+}
+
+// getAttResource returns the resource named by a GetAtt, written either as
+// "Resource.Attribute" or [Resource, Attribute].
+func getAttResource(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		name, _, _ := strings.Cut(v, ".")
+		return name
+	case []interface{}:
+		if len(v) > 0 {
+			name, _ := v[0].(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// subReferences returns the names used by ${} variables in a Sub, which is
+// either a string or a [string, variables] pair. Names defined in the
+// variables map are local to the Sub, but their values may refer to others.
+func subReferences(v interface{}, locals map[string]bool, walk func(interface{}, map[string]bool)) []string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		s, _ = v[0].(string)
+		if len(v) > 1 {
+			vars, _ := v[1].(map[string]interface{})
+			for _, value := range vars {
+				walk(value, locals)
+			}
+			inner := map[string]bool{}
+			for name := range locals {
+				inner[name] = true
+			}
+			for name := range vars {
+				inner[name] = true
+			}
+			locals = inner
+		}
+	}
+
+	var out []string
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			break
+		}
+		name := s[start+2 : start+end]
+		s = s[start+end+1:]
+		if strings.HasPrefix(name, "!") {
+			// ${!Literal} is written out as is
+			continue
+		}
+		name, _, _ = strings.Cut(name, ".")
+		if !locals[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// dependencies lists the resources a resource definition depends on,
+// explicitly with DependsOn or implicitly through references in its
+// properties, keeping only names that are resources in the template.
+func dependencies(def map[string]interface{}, resources map[string]interface{}) []string {
+	names := map[string]bool{}
+	switch d := def["DependsOn"].(type) {
+	case string:
+		names[d] = true
+	case []interface{}:
+		for _, n := range d {
+			if s, ok := n.(string); ok {
+				names[s] = true
+			}
+		}
+	}
+	for _, n := range references(def["Properties"]) {
+		names[n] = true
+	}
+
+	out := []string{}
+	for n := range names {
+		if _, ok := resources[n]; ok {
+			out = append(out, n)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Dependents returns the resources which depend on the named resource, in
+// order.
+func (t *Template) Dependents(name string) []string {
+	out := []string{}
+	for _, other := range t.Names() {
+		for _, d := range t.Resources[other].DependsOn {
+			if d == name {
+				out = append(out, other)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Roots returns the resources which depend on nothing else, in order.
+func (t *Template) Roots() []string {
+	out := []string{}
+	for _, name := range t.Names() {
+		if len(t.Resources[name].DependsOn) == 0 {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+// Package template reads the parts of a CloudFormation template that the
+// watcher needs, from either JSON or YAML.
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Template is a parsed CloudFormation template.
+type Template struct {
+	Resources map[string]Resource
+}
+
+// Resource is a resource declared in a template.
+type Resource struct {
+	Type string
+	// Condition names the condition controlling whether the resource is
+	// created at all, if any.
+	Condition string
+	// DependsOn lists the resources this one depends on, whether through
+	// DependsOn or by referring to them, so cannot start before.
+	DependsOn []string
+}
+
+// Parse reads a template body, which may be JSON or YAML.
+func Parse(body string) (*Template, error) {
+	var doc interface{}
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		if err := json.Unmarshal([]byte(body), &doc); err != nil {
+			return nil, fmt.Errorf("parsing JSON template: %w", err)
+		}
+	} else {
+		var err error
+		doc, err = parseYAML(body)
+		if err != nil {
+			return nil, fmt.Errorf("parsing YAML template: %w", err)
+		}
+	}
+
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("template is not a mapping")
+	}
+	resources, ok := root["Resources"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("template has no resources")
+	}
+
+	t := &Template{Resources: map[string]Resource{}}
+	for name, v := range resources {
+		def, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("resource %s is not a mapping", name)
+		}
+		r := Resource{}
+		r.Type, _ = def["Type"].(string)
+		r.Condition, _ = def["Condition"].(string)
+		r.DependsOn = dependencies(def, resources)
+		t.Resources[name] = r
+	}
+	return t, nil
+}
+
+// Names returns the logical IDs of every resource, in order.
+func (t *Template) Names() []string {
+	out := make([]string, 0, len(t.Resources))
+	for name := range t.Resources {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,218 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+const jsonTemplate = `{
+  "AWSTemplateFormatVersion": "2010-09-09",
+  "Conditions": {"IsProd": {"Fn::Equals": [{"Ref": "Env"}, "prod"]}},
+  "Resources": {
+    "Bucket": {"Type": "AWS::S3::Bucket"},
+    "Alarm": {"Type": "AWS::CloudWatch::Alarm", "Condition": "IsProd"}
+  }
+}`
+
+const yamlTemplate = `AWSTemplateFormatVersion: "2010-09-09"
+Description: >
+  A template which exercises the
+  YAML that CloudFormation accepts # not a comment in here
+Parameters:
+  Env:
+    Type: String # the environment
+Conditions:
+  IsProd: !Equals [!Ref Env, prod]
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Sub '${AWS::StackName}-bucket'
+      Tags:
+        - Key: env
+          Value: !Ref Env
+        - Key: "quoted: key"
+          Value: 'it''s # here'
+  Alarm:
+    Type: 'AWS::CloudWatch::Alarm'
+    Condition: IsProd
+    Properties:
+      Dimensions:
+      - Name: Bucket
+        Value: !GetAtt Bucket.Arn
+      AlarmActions: [
+        !Ref Topic,
+      ]
+  Function:
+    Type: AWS::Lambda::Function
+    Properties:
+      Code:
+        ZipFile: |
+          def handler(event, context):
+              return "ok: done"
+      Role:
+        Fn::GetAtt:
+        - Role
+        - Arn
+`
+
+func TestParseJSON(t *testing.T) {
+	is := is.New(t)
+
+	tmpl, err := Parse(jsonTemplate)
+	is.NoErr(err)
+	is.Equal(tmpl.Names(), []string{"Alarm", "Bucket"})
+	is.Equal(tmpl.Resources["Bucket"], Resource{Type: "AWS::S3::Bucket", DependsOn: []string{}})
+	is.Equal(tmpl.Resources["Alarm"], Resource{Type: "AWS::CloudWatch::Alarm", Condition: "IsProd", DependsOn: []string{}})
+}
+
+func TestParseYAML(t *testing.T) {
+	is := is.New(t)
+
+	tmpl, err := Parse(yamlTemplate)
+	is.NoErr(err)
+	is.Equal(tmpl.Names(), []string{"Alarm", "Bucket", "Function"})
+	is.Equal(tmpl.Resources["Alarm"].Type, "AWS::CloudWatch::Alarm")
+	is.Equal(tmpl.Resources["Alarm"].Condition, "IsProd")
+	is.Equal(tmpl.Resources["Alarm"].DependsOn, []string{"Bucket"}) // Topic is not a resource
+	is.Equal(tmpl.Resources["Function"].Type, "AWS::Lambda::Function")
+}
+
+func TestParseYAMLStructure(t *testing.T) {
+	is := is.New(t)
+
+	doc, err := parseYAML(yamlTemplate)
+	is.NoErr(err)
+	root := doc.(map[string]interface{})
+
+	is.Equal(root["Description"], "A template which exercises the YAML that CloudFormation accepts # not a comment in here\n")
+	is.Equal(root["Conditions"], map[string]interface{}{
+		"IsProd": map[string]interface{}{
+			"Fn::Equals": []interface{}{map[string]interface{}{"Ref": "Env"}, "prod"},
+		},
+	})
+
+	resources := root["Resources"].(map[string]interface{})
+	bucket := resources["Bucket"].(map[string]interface{})["Properties"].(map[string]interface{})
+	is.Equal(bucket["BucketName"], map[string]interface{}{"Fn::Sub": "${AWS::StackName}-bucket"})
+	is.Equal(bucket["Tags"], []interface{}{
+		map[string]interface{}{"Key": "env", "Value": map[string]interface{}{"Ref": "Env"}},
+		map[string]interface{}{"Key": "quoted: key", "Value": "it's # here"},
+	})
+
+	alarm := resources["Alarm"].(map[string]interface{})["Properties"].(map[string]interface{})
+	is.Equal(alarm["Dimensions"], []interface{}{
+		map[string]interface{}{"Name": "Bucket", "Value": map[string]interface{}{"Fn::GetAtt": "Bucket.Arn"}},
+	})
+	is.Equal(alarm["AlarmActions"], []interface{}{map[string]interface{}{"Ref": "Topic"}})
+
+	function := resources["Function"].(map[string]interface{})["Properties"].(map[string]interface{})
+	is.Equal(function["Code"], map[string]interface{}{
+		"ZipFile": "def handler(event, context):\n    return \"ok: done\"\n",
+	})
+	is.Equal(function["Role"], map[string]interface{}{"Fn::GetAtt": []interface{}{"Role", "Arn"}})
+}
+
+func TestParseInvalid(t *testing.T) {
+	is := is.New(t)
+
+	_, err := Parse(`{"Resources": `)
+	is.True(err != nil)
+
+	_, err = Parse("Description: no resources here\n")
+	is.True(err != nil)
+}
+
+func TestParseYAMLMalformedFlow(t *testing.T) {
+	is := is.New(t)
+
+	_, err := parseYAML("Key: [a, b}\n")
+	is.True(err != nil)
+	_, err = parseYAML("Key: {a: b]\n")
+	is.True(err != nil)
+}
+
+func TestParseYAMLScalars(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+		want        interface{}
+	}{
+		{
+			name:  "quoted across lines",
+			input: "A: \"multi\n  line\"\n",
+			want:  "multi line",
+		},
+		{
+			name:  "block scalar with comments and blank lines",
+			input: "A:\n  Fn::Sub: |\n    #!/bin/bash\n\n    echo ${Bucket}\n",
+			want:  map[string]interface{}{"Fn::Sub": "#!/bin/bash\n\necho ${Bucket}\n"},
+		},
+		{
+			name:  "anchor and alias",
+			input: "B: &name !Ref Env\nA: *name\n",
+			want:  map[string]interface{}{"Ref": "Env"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			doc, err := parseYAML(tc.input)
+			is.NoErr(err)
+			is.Equal(doc.(map[string]interface{})["A"], tc.want)
+		})
+	}
+}
+
+func TestParseYAMLMerge(t *testing.T) {
+	is := is.New(t)
+
+	doc, err := parseYAML("Base: &base\n  Type: AWS::S3::Bucket\n  Condition: IsProd\nA:\n  <<: *base\n  Condition: IsDev\n")
+	is.NoErr(err)
+	is.Equal(doc.(map[string]interface{})["A"], map[string]interface{}{"Type": "AWS::S3::Bucket", "Condition": "IsDev"})
+}
+
+const graphTemplate = `
+Resources:
+  Role:
+    Type: AWS::IAM::Role
+  Bucket:
+    Type: AWS::S3::Bucket
+  Queue:
+    Type: AWS::SQS::Queue
+    DependsOn: Bucket
+  Function:
+    Type: AWS::Lambda::Function
+    DependsOn: [Queue]
+    Properties:
+      Role: !GetAtt [Role, Arn]
+      Environment:
+        Variables:
+          BUCKET: !Sub "arn:${AWS::Partition}:s3:::${Bucket}/${!Literal}"
+          TABLE:
+            Fn::Sub:
+              - "${Name}-${Suffix}"
+              - Name: !Ref Table
+                Suffix: fixed
+  Table:
+    Type: AWS::DynamoDB::Table
+  Alias:
+    Type: AWS::Lambda::Alias
+    Properties:
+      FunctionName: !Ref Function
+      FunctionVersion: !GetAtt Function.Version
+`
+
+func TestDependencies(t *testing.T) {
+	is := is.New(t)
+
+	tmpl, err := Parse(graphTemplate)
+	is.NoErr(err)
+
+	is.Equal(tmpl.Resources["Function"].DependsOn, []string{"Bucket", "Queue", "Role", "Table"})
+	is.Equal(tmpl.Resources["Alias"].DependsOn, []string{"Function"})
+	is.Equal(tmpl.Resources["Queue"].DependsOn, []string{"Bucket"})
+
+	is.Equal(tmpl.Roots(), []string{"Bucket", "Role", "Table"})
+	is.Equal(tmpl.Dependents("Bucket"), []string{"Function", "Queue"})
+	is.Equal(tmpl.Dependents("Alias"), []string{})
+}
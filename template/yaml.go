@@ -0,0 +1,116 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML reads a YAML template into the same shape as a JSON one: every
+// scalar is returned as a string, aliases are replaced by what they refer
+// to, and the short form intrinsic function tags such as !Ref are expanded
+// to their long form.
+func parseYAML(body string) (interface{}, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+	return convert(doc.Content[0])
+}
+
+// convert turns a node into the strings, slices and maps that
+// encoding/json would produce for the equivalent JSON.
+func convert(n *yaml.Node) (interface{}, error) {
+	var v interface{}
+	switch n.Kind {
+	case yaml.AliasNode:
+		return convert(n.Alias)
+	case yaml.ScalarNode:
+		v = n.Value
+	case yaml.SequenceNode:
+		items := make([]interface{}, 0, len(n.Content))
+		for _, c := range n.Content {
+			item, err := convert(c)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		v = items
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		if err := convertMapping(n, m); err != nil {
+			return nil, err
+		}
+		v = m
+	default:
+		return nil, fmt.Errorf("line %d: unexpected YAML node", n.Line)
+	}
+	return applyTag(n.Tag, v), nil
+}
+
+// convertMapping adds the entries of a mapping node to m. Entries merged in
+// with << do not replace those given explicitly.
+func convertMapping(n *yaml.Node, m map[string]interface{}) error {
+	var merged []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Tag == "!!merge" {
+			merged = append(merged, value)
+			continue
+		}
+		if key.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+		}
+		v, err := convert(value)
+		if err != nil {
+			return err
+		}
+		m[key.Value] = v
+	}
+
+	for _, value := range merged {
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		sources := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, src := range sources {
+			if src.Kind == yaml.AliasNode {
+				src = src.Alias
+			}
+			if src.Kind != yaml.MappingNode {
+				return fmt.Errorf("line %d: only mappings can be merged", src.Line)
+			}
+			extra := map[string]interface{}{}
+			if err := convertMapping(src, extra); err != nil {
+				return err
+			}
+			for k, v := range extra {
+				if _, ok := m[k]; !ok {
+					m[k] = v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyTag expands a short form intrinsic function tag, such as !Ref, to
+// the long form used in JSON templates.
+func applyTag(tag string, v interface{}) interface{} {
+	switch {
+	case tag == "" || tag == "!" || strings.HasPrefix(tag, "!!"):
+		return v
+	case tag == "!Ref" || tag == "!Condition":
+		return map[string]interface{}{tag[1:]: v}
+	default:
+		return map[string]interface{}{"Fn::" + tag[1:]: v}
+	}
+}
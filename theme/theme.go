@@ -0,0 +1,265 @@
+// Package theme defines the styles and glyphs used to draw stack state, so
+// that state can be told apart with or without colour.
+package theme
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Category is a class of state which is drawn the same way.
+type Category string
+
+const (
+	Default     Category = "default"
+	Pending     Category = "pending"
+	OK          Category = "ok"
+	Updating    Category = "updating"
+	Failed      Category = "failed"
+	RollingBack Category = "rolling-back"
+	RolledBack  Category = "rolled-back"
+	Skipped     Category = "skipped"
+	// Stuck is for resources which have been in progress for suspiciously
+	// long.
+	Stuck Category = "stuck"
+)
+
+// Categories lists every category a theme defines.
+var Categories = []Category{Default, Pending, OK, Updating, Failed, RollingBack, RolledBack, Skipped, Stuck}
+
+// Theme maps each category to a style, and optionally a glyph which is drawn
+// in front of each row.
+type Theme struct {
+	Name   string
+	Styles map[Category]tcell.Style
+	Glyphs map[Category]string
+}
+
+var base = tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
+
+var symbols = map[Category]string{
+	Default:     "·",
+	Pending:     "…",
+	OK:          "✔",
+	Updating:    "◌",
+	Failed:      "✘",
+	RollingBack: "↺",
+	RolledBack:  "↶",
+	Skipped:     "–",
+	Stuck:       "⧖",
+}
+
+var builtins = map[string]func() Theme{
+	"default": func() Theme {
+		return Theme{
+			Name: "default",
+			Styles: map[Category]tcell.Style{
+				Default:     base,
+				Pending:     base.Dim(true),
+				OK:          base.Foreground(tcell.ColorGreen),
+				Updating:    base.Foreground(tcell.ColorBlue),
+				Failed:      base.Foreground(tcell.ColorRed),
+				RollingBack: base.Foreground(tcell.ColorYellow),
+				RolledBack:  base.Foreground(tcell.ColorOlive),
+				Skipped:     base.Foreground(tcell.ColorGray),
+				Stuck:       base.Foreground(tcell.ColorFuchsia).Bold(true),
+			},
+			Glyphs: map[Category]string{},
+		}
+	},
+	"high-contrast": func() Theme {
+		return Theme{
+			Name: "high-contrast",
+			Styles: map[Category]tcell.Style{
+				Default:     base.Bold(true),
+				Pending:     base,
+				OK:          base.Foreground(tcell.ColorLime).Bold(true),
+				Updating:    base.Foreground(tcell.ColorAqua).Bold(true),
+				Failed:      base.Foreground(tcell.ColorWhite).Background(tcell.ColorRed).Bold(true),
+				RollingBack: base.Foreground(tcell.ColorBlack).Background(tcell.ColorYellow).Bold(true),
+				RolledBack:  base.Foreground(tcell.ColorYellow).Bold(true),
+				Skipped:     base.Foreground(tcell.ColorSilver),
+				Stuck:       base.Foreground(tcell.ColorBlack).Background(tcell.ColorFuchsia).Bold(true),
+			},
+			Glyphs: copyGlyphs(symbols),
+		}
+	},
+	// deuteranopia uses the Okabe-Ito palette, which avoids relying on red
+	// and green being distinguishable. Only one blue is used, as the palette's
+	// two blues are hard to tell apart
+	"deuteranopia": func() Theme {
+		return Theme{
+			Name: "deuteranopia",
+			Styles: map[Category]tcell.Style{
+				Default:     base,
+				Pending:     base.Dim(true),
+				OK:          base.Foreground(tcell.NewHexColor(0x0072b2)),
+				Updating:    base.Foreground(tcell.NewHexColor(0xf0e442)),
+				Failed:      base.Foreground(tcell.NewHexColor(0xd55e00)).Bold(true),
+				RollingBack: base.Foreground(tcell.NewHexColor(0xe69f00)).Bold(true),
+				RolledBack:  base.Foreground(tcell.NewHexColor(0xcc79a7)),
+				Skipped:     base.Foreground(tcell.NewHexColor(0x999999)),
+				Stuck:       base.Foreground(tcell.ColorBlack).Background(tcell.NewHexColor(0xf0e442)).Bold(true),
+			},
+			Glyphs: copyGlyphs(symbols),
+		}
+	},
+	"monochrome": func() Theme {
+		return Theme{
+			Name: "monochrome",
+			Styles: map[Category]tcell.Style{
+				Default:     base,
+				Pending:     base.Dim(true),
+				OK:          base,
+				Updating:    base.Bold(true),
+				Failed:      base.Reverse(true),
+				RollingBack: base.Bold(true).Underline(true),
+				RolledBack:  base.Underline(true),
+				Skipped:     base.Dim(true),
+				Stuck:       base.Bold(true).Reverse(true),
+			},
+			Glyphs: copyGlyphs(symbols),
+		}
+	},
+}
+
+func copyGlyphs(g map[Category]string) map[Category]string {
+	out := map[Category]string{}
+	for k, v := range g {
+		out[k] = v
+	}
+	return out
+}
+
+// Names lists the built in themes.
+func Names() []string {
+	out := []string{}
+	for name := range builtins {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Builtin returns the named built in theme.
+func Builtin(name string) (Theme, bool) {
+	f, ok := builtins[name]
+	if !ok {
+		return Theme{}, false
+	}
+	return f(), true
+}
+
+// NoColor returns whether the user has asked for no colour, following
+// https://no-color.org.
+func NoColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// DefaultName is the theme used when none is chosen.
+func DefaultName() string {
+	if NoColor() {
+		return "monochrome"
+	}
+	return "default"
+}
+
+func (t Theme) Style(c Category) tcell.Style {
+	if s, ok := t.Styles[c]; ok {
+		return s
+	}
+	return t.Styles[Default]
+}
+
+func (t Theme) Glyph(c Category) string {
+	return t.Glyphs[c]
+}
+
+// Set changes a single entry of the theme. Keys are either a category, to
+// set its style, or glyph.CATEGORY to set its glyph.
+func (t *Theme) Set(key, value string) error {
+	if name, ok := strings.CutPrefix(key, "glyph."); ok {
+		c, err := parseCategory(name)
+		if err != nil {
+			return err
+		}
+		t.Glyphs[c] = value
+		return nil
+	}
+
+	c, err := parseCategory(key)
+	if err != nil {
+		return err
+	}
+	style, err := ParseStyle(value)
+	if err != nil {
+		return err
+	}
+	t.Styles[c] = style
+	return nil
+}
+
+func parseCategory(name string) (Category, error) {
+	for _, c := range Categories {
+		if string(c) == name {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown style %q", name)
+}
+
+// ParseStyle reads a style such as "red", "#d55e00 bold" or "white on red
+// reverse". Colours are tcell colour names or #rrggbb values.
+func ParseStyle(spec string) (tcell.Style, error) {
+	style := base
+	words := strings.Fields(spec)
+	for i := 0; i < len(words); i++ {
+		switch w := strings.ToLower(words[i]); w {
+		case "bold":
+			style = style.Bold(true)
+		case "dim":
+			style = style.Dim(true)
+		case "italic":
+			style = style.Italic(true)
+		case "underline":
+			style = style.Underline(true)
+		case "reverse":
+			style = style.Reverse(true)
+		case "blink":
+			style = style.Blink(true)
+		case "on":
+			if i+1 >= len(words) {
+				return style, fmt.Errorf("missing background colour in %q", spec)
+			}
+			i++
+			c, err := parseColour(words[i])
+			if err != nil {
+				return style, err
+			}
+			style = style.Background(c)
+		default:
+			c, err := parseColour(w)
+			if err != nil {
+				return style, err
+			}
+			style = style.Foreground(c)
+		}
+	}
+	return style, nil
+}
+
+func parseColour(name string) (tcell.Color, error) {
+	name = strings.ToLower(name)
+	if name == "default" || name == "reset" {
+		return tcell.ColorReset, nil
+	}
+	c := tcell.GetColor(name)
+	if c == tcell.ColorDefault {
+		return c, fmt.Errorf("unknown colour %q", name)
+	}
+	return c, nil
+}
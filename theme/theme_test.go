@@ -0,0 +1,76 @@
+package theme
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/matryer/is"
+)
+
+func TestParseStyle(t *testing.T) {
+	is := is.New(t)
+
+	style, err := ParseStyle("white on red bold")
+	is.NoErr(err)
+	fg, bg, attrs := style.Decompose()
+	is.Equal(fg, tcell.ColorWhite)
+	is.Equal(bg, tcell.ColorRed)
+	is.Equal(attrs&tcell.AttrBold, tcell.AttrBold)
+
+	style, err = ParseStyle("#d55e00")
+	is.NoErr(err)
+	fg, _, _ = style.Decompose()
+	is.Equal(fg, tcell.NewHexColor(0xd55e00))
+
+	_, err = ParseStyle("not-a-colour")
+	is.True(err != nil)
+
+	_, err = ParseStyle("red on")
+	is.True(err != nil)
+}
+
+func TestSet(t *testing.T) {
+	is := is.New(t)
+
+	theme, ok := Builtin("default")
+	is.True(ok)
+	is.Equal(theme.Glyph(Failed), "")
+
+	is.NoErr(theme.Set("failed", "maroon"))
+	is.NoErr(theme.Set("glyph.failed", "X"))
+	fg, _, _ := theme.Style(Failed).Decompose()
+	is.Equal(fg, tcell.ColorMaroon)
+	is.Equal(theme.Glyph(Failed), "X")
+
+	is.True(theme.Set("bogus", "red") != nil)
+	is.True(theme.Set("glyph.bogus", "X") != nil)
+}
+
+func TestBuiltinsAreIndependent(t *testing.T) {
+	is := is.New(t)
+
+	a, _ := Builtin("monochrome")
+	is.NoErr(a.Set("glyph.ok", "+"))
+	b, _ := Builtin("monochrome")
+	is.Equal(b.Glyph(OK), "✔")
+}
+
+func TestBuiltinsDefineEveryCategory(t *testing.T) {
+	for _, name := range Names() {
+		theme, _ := Builtin(name)
+		for _, c := range Categories {
+			if _, ok := theme.Styles[c]; !ok {
+				t.Errorf("theme %s is missing a style for %s", name, c)
+			}
+		}
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	is := is.New(t)
+
+	t.Setenv("NO_COLOR", "1")
+	is.Equal(DefaultName(), "monochrome")
+	t.Setenv("NO_COLOR", "")
+	is.Equal(DefaultName(), "default")
+}
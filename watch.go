@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/simonrw/cflivestatus/estimate"
+	"github.com/simonrw/cflivestatus/fetcher"
+	"github.com/simonrw/cflivestatus/runner"
+)
+
+// highlightStep is how often the screen is redrawn while changed rows fade.
+const highlightStep = 500 * time.Millisecond
+
+// watch shows the stack until the user quits or, in plain mode, until the
+// operation finishes.
+func (a *app) watch(ctx context.Context) error {
+	return a.display(ctx, nil)
+}
+
+// monitor runs the terminal UI until the user quits or, if proc is not nil,
+// until proc exits. Its output is shown beneath the resource table.
+func (a *app) monitor(ctx context.Context, proc *runner.Process) error {
+	alertCfg, err := newAlertConfig(a.opts.BellOn, a.opts.DesktopOn, a.opts.FlashOn, a.opts.OSCNotify)
+	if err != nil {
+		return fmt.Errorf("invalid alert configuration: %w", err)
+	}
+	alerts := make(alertNotifier, 16)
+
+	dispatcher, err := newDispatcher(a.opts.Webhooks, a.opts.WebhookTemplate, a.opts.NotifyOn, a.opts.StuckAfter, alerts)
+	if err != nil {
+		return fmt.Errorf("invalid notification configuration: %w", err)
+	}
+
+	// update resources goroutine
+	eventsCh := make(chan *fetcher.Snapshot)
+	stop := a.startPolling(ctx, dispatcher, eventsCh)
+	defer stop()
+
+	// a wrapped command may not have created the stack yet, so there is
+	// nothing to wait for
+	var last *fetcher.Snapshot
+	if proc == nil {
+		last = <-eventsCh
+	}
+
+	// rows that just changed are redrawn every highlightStep while their
+	// highlight fades
+	var highlight <-chan time.Time
+
+	keys, err := newKeymap(a.opts.Keys)
+	if err != nil {
+		return fmt.Errorf("invalid key bindings: %w", err)
+	}
+	columns, err := parseColumns(a.opts.Columns)
+	if err != nil {
+		return err
+	}
+	t, err := loadTheme(a.opts.Theme, a.settings, a.opts.Colours, a.opts.Glyphs)
+	if err != nil {
+		return err
+	}
+
+	screen, err := NewScreen(a.identity, displayOptions{columns: columns, sort: a.opts.Sort, stuckAfter: a.opts.StuckAfter}, t)
+	if err != nil {
+		return err
+	}
+	screen.SetPaths(a.paths)
+	screen.SetKeys(keys)
+	screen.SetLogs(a.logs.buffer)
+	// logs would scribble over the screen, so are only kept for the log
+	// pane and the log file while it is drawn
+	a.logs.hide(screen.Close)
+	defer a.logs.show()
+	if last != nil {
+		screen.Observe(last)
+	}
+	var output <-chan struct{}
+	var exited <-chan struct{}
+	if proc != nil {
+		screen.SetOutput([]string{})
+		output = proc.Updates()
+		exited = proc.Done()
+	}
+	render := func() {
+		screen.Render(last)
+		if screen.Highlighting() {
+			highlight = time.After(highlightStep)
+		}
+	}
+	render()
+
+	// background goroutine that sends key presses to the main render loop
+	keyPresses := make(chan *tcell.EventKey)
+	closed := make(chan struct{})
+	defer close(closed)
+	go readKeys(screen, keyPresses, closed)
+
+	// results reports the outcome of actions which change the stack
+	results := make(chan string)
+	// a load still running when the UI exits must not block
+	histories := make(chan historyResult, 1)
+	estimates := make(chan *estimate.Estimator, 1)
+	a.loadEstimates(ctx, estimates)
+
+	// the clock and the time each resource has spent in its state tick on
+	// between polls
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	var flashDone <-chan time.Time
+	flash := func(message string) {
+		screen.Flash(message)
+		flashDone = time.After(flashDuration)
+	}
+	for {
+		select {
+		case ev := <-keyPresses:
+			if dismissesHelp(screen, ev) {
+				screen.ToggleHelp(nil)
+				render()
+				continue
+			}
+			act := keys.lookup(ev)
+			if act == actionQuit && (screen.prompt == nil || ev.Key() == tcell.KeyCtrlC) {
+				screen.Close()
+				return nil
+			}
+			if screen.prompt != nil {
+				screen.prompt = screen.prompt.handle(ev)
+				render()
+				continue
+			}
+
+			var err error
+			switch act {
+			case actionRedraw:
+				screen.Sync()
+			case actionToggleHooks:
+				screen.ToggleView(hooksView)
+			case actionToggleGraph:
+				screen.ToggleView(graphView)
+			case actionUp:
+				screen.MoveCursor(-1)
+			case actionDown:
+				screen.MoveCursor(1)
+			case actionDetails:
+				err = screen.ToggleDetails()
+			case actionToggleLogs:
+				screen.ToggleLogs()
+			case actionToggleHistory:
+				if screen.ToggleHistory() {
+					a.loadHistory(ctx, histories)
+				}
+			case actionLeft:
+				screen.StepReplay(-1)
+			case actionRight:
+				screen.StepReplay(1)
+			case actionToggleTimeline:
+				screen.ToggleTimeline()
+			case actionPause:
+				screen.SetPaused(a.polling.TogglePause())
+			case actionRefresh:
+				a.polling.Refresh()
+				flash("refreshing")
+			case actionLongerInterval:
+				flash(a.polling.Step(1))
+			case actionShorterInterval:
+				flash(a.polling.Step(-1))
+			case actionToggleHelp:
+				screen.ToggleHelp(keys.help())
+			case actionCancelUpdate:
+				screen.prompt, err = a.cancelUpdate(ctx, last, results)
+			case actionContinueRollback:
+				screen.prompt, err = a.continueRollback(ctx, last, results)
+			}
+			if err != nil {
+				flash(err.Error())
+			}
+			render()
+		case <-output:
+			screen.SetOutput(proc.Lines())
+			render()
+		case <-a.logs.buffer.Updates():
+			if screen.ShowingLogs() {
+				render()
+			}
+		case <-exited:
+			screen.Close()
+			return nil
+		case message := <-results:
+			flash(message)
+			render()
+		case h := <-histories:
+			screen.SetHistory(h)
+			render()
+		case e := <-estimates:
+			screen.SetEstimator(e)
+			render()
+		case last = <-eventsCh:
+			screen.Observe(last)
+			// a poll asked for while paused is shown, and the view frozen
+			// again at its time
+			if screen.Paused() {
+				screen.SetPaused(true)
+			}
+			render()
+		case e := <-alerts:
+			if screen.Alert(e, alertCfg) {
+				render()
+				flashDone = time.After(flashDuration)
+			}
+		case <-flashDone:
+			flashDone = nil
+			render()
+		case <-highlight:
+			highlight = nil
+			render()
+		case <-tick.C:
+			render()
+		}
+	}
+}
+
+// readKeys sends key presses to keys, and keeps the screen the right size,
+// until the screen is closed.
+func readKeys(screen *Screen, keys chan<- *tcell.EventKey, closed <-chan struct{}) {
+	for {
+		switch ev := screen.PollEvent().(type) {
+		case nil:
+			// the screen has been closed
+			return
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			select {
+			case keys <- ev:
+			case <-closed:
+				return
+			}
+		}
+	}
+}
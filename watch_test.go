@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/matryer/is"
+)
+
+func TestReadKeysStopsWhenScreenCloses(t *testing.T) {
+	is := is.New(t)
+
+	sim := tcell.NewSimulationScreen("")
+	is.NoErr(sim.Init())
+	var ts tcell.Screen = sim
+	screen := &Screen{s: &ts}
+
+	keys := make(chan *tcell.EventKey)
+	closed := make(chan struct{})
+	defer close(closed)
+	done := make(chan struct{})
+	go func() {
+		readKeys(screen, keys, closed)
+		close(done)
+	}()
+
+	sim.InjectKey(tcell.KeyRune, 'p', tcell.ModNone)
+	select {
+	case ev := <-keys:
+		is.Equal(ev.Rune(), 'p')
+	case <-time.After(time.Second):
+		t.Fatal("no key press was read")
+	}
+
+	screen.Close()
+	is.True(returned(done, time.Second))
+}